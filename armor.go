@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Armor lines around dog speech, PEM style, so a pasted block says what it is.
+const (
+	armorBegin   = "-----BEGIN WOOFWOOF-----"
+	armorEnd     = "-----END WOOFWOOF-----"
+	armorVersion = 1 // version of the armor layout; FormatVersion covers the frame inside
+)
+
+// Armor wraps dogSpeech in BEGIN/END lines with "Version" and "Tokens" header
+// lines. tokens is the frame's token count from armorTokens, taken before the
+// body is laid out with runs, delimiters or other forms that change its fields.
+func Armor(dogSpeech string, tokens int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s\nVersion: %d\nTokens: %d\n\n%s\n%s", armorBegin, armorVersion, tokens, dogSpeech, armorEnd)
+	return sb.String()
+}
+
+// Dearmor returns the body of an armored block and the token count its header
+// declares, or -1 without one. Input without a BEGIN line is returned
+// unchanged, so it can run on every decode input. The count is only known to
+// match once the body is plain tokens again; see checkArmorTokens.
+func Dearmor(s string) (body string, tokens int, err error) {
+	_, rest, ok := strings.Cut(s, armorBegin)
+	if !ok {
+		return s, -1, nil
+	}
+	block, _, ok := strings.Cut(rest, armorEnd)
+	if !ok {
+		return "", -1, fmt.Errorf("armor: missing %s line", armorEnd)
+	}
+
+	block = strings.ReplaceAll(block, "\r\n", "\n")
+	headers, body, _ := strings.Cut(strings.TrimLeft(block, "\n"), "\n\n")
+	tokens = -1
+	for _, line := range strings.Split(headers, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			return "", -1, fmt.Errorf("armor: malformed header line %q", line)
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "Version":
+			if value != strconv.Itoa(armorVersion) {
+				return "", -1, fmt.Errorf("armor: unsupported version %q", value)
+			}
+		case "Tokens":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return "", -1, fmt.Errorf("armor: bad token count %q", value)
+			}
+			tokens = n
+		}
+	}
+	return strings.TrimSpace(body), tokens, nil
+}
+
+// armorTokens counts the frame tokens of speech: every field but a trailing
+// pad token, or one per two runes of the spaceless form.
+func armorTokens(speech string, spaceless bool) int {
+	if spaceless {
+		return utf8.RuneCountInString(strings.TrimSpace(speech)) / 2
+	}
+	speech, _ = trimPadToken(speech)
+	return countTokens(speech)
+}
+
+// checkArmorTokens compares the count from Dearmor with the frame tokens of
+// speech, the armored body with its layout undone. declared < 0 means the
+// block had no Tokens header.
+func checkArmorTokens(declared int, speech string, spaceless bool) error {
+	if n := armorTokens(speech, spaceless); declared >= 0 && n != declared {
+		return fmt.Errorf("armor: header declares %d tokens, body has %d", declared, n)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestArmorRoundTrip(t *testing.T) {
+	const text = "aaaaaaaaaaaa"
+	frame := countTokens(mustEncode(t, text))
+	for _, tc := range []struct {
+		flag   string
+		decode []string
+		tokens int
+	}{
+		{"--rle", nil, frame},
+		{"--delimiter", []string{"--delimiter"}, frame},
+		{"--pad-token", nil, frame},
+		{"--pretty", nil, frame},
+		{"--spaceless", []string{"--spaceless"}, 2 * (4 + len(text))},
+	} {
+		out, _, err := runCLI(t, "", "encode", "--armor", tc.flag, text)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.flag, err)
+		}
+		if !strings.HasPrefix(out, armorBegin+"\n") || !strings.HasSuffix(out, armorEnd+"\n") {
+			t.Fatalf("%s: markers missing in %q", tc.flag, out)
+		}
+		if !strings.Contains(out, fmt.Sprintf("\nTokens: %d\n", tc.tokens)) {
+			t.Errorf("%s: want Tokens: %d in %q", tc.flag, tc.tokens, out)
+		}
+		got, _, err := runCLI(t, out, append([]string{"decode"}, tc.decode...)...)
+		if err != nil || got != text+"\n" {
+			t.Errorf("%s: decode got %q, %v", tc.flag, got, err)
+		}
+	}
+}
+
+func TestDearmorTokenCount(t *testing.T) {
+	speech := mustEncode(t, "woof")
+	n := countTokens(speech)
+	for _, tc := range []struct {
+		declared int
+		ok       bool
+	}{{n, true}, {n - 1, false}, {n + 1, false}} {
+		body, declared, err := Dearmor(Armor(speech, tc.declared))
+		if err != nil || body != speech || declared != tc.declared {
+			t.Fatalf("Dearmor: %q, %d, %v", body, declared, err)
+		}
+		if err := checkArmorTokens(declared, body, false); (err == nil) != tc.ok {
+			t.Errorf("declared %d of %d: %v", tc.declared, n, err)
+		}
+	}
+	if _, err := Canonicalize(Armor(speech, n+1)); err == nil || !strings.Contains(err.Error(), "header declares") {
+		t.Errorf("Canonicalize with a wrong count: %v", err)
+	}
+	if body, declared, err := Dearmor(speech); err != nil || body != speech || declared != -1 {
+		t.Errorf("unarmored input: %q, %d, %v", body, declared, err)
+	}
+}
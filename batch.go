@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// lineResult is one line of DecodeJSONLines output.
+type lineResult struct {
+	Line  int     `json:"line"`
+	Text  *string `json:"text,omitempty"`
+	Error string  `json:"error,omitempty"`
+}
+
+// DecodeJSONLines decodes every line of r as its own dog-speech message and
+// writes one JSON object per line to w: {"line":N,"text":"..."} on success or
+// {"line":N,"error":"..."} on failure. Lines are handled as they are read, so
+// memory use does not grow with the input. The returned error covers only
+// reading and writing; per-line decode failures are reported in the output.
+func DecodeJSONLines(r io.Reader, w io.Writer) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	for line := 1; sc.Scan(); line++ {
+		res := lineResult{Line: line}
+		text, err := Decode(sc.Text())
+		if err != nil {
+			res.Error = err.Error()
+		} else {
+			res.Text = &text
+		}
+		if err := enc.Encode(res); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// EncodeBatch encodes every input like Encode, using at most concurrency
+// goroutines (GOMAXPROCS when concurrency < 1). outputs[i] and errs[i] belong to
+// inputs[i] whatever order the work finishes in; errs[i] is nil on success.
+func EncodeBatch(inputs []string, concurrency int) (outputs []string, errs []error) {
+	if concurrency < 1 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	concurrency = min(concurrency, len(inputs))
+	outputs = make([]string, len(inputs))
+	errs = make([]error, len(inputs))
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Go(func() {
+			for i := range next {
+				outputs[i], errs[i] = Encode(inputs[i])
+			}
+		})
+	}
+	for i := range inputs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return outputs, errs
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEncodeBatch(t *testing.T) {
+	var inputs []string
+	for i := range 50 {
+		if i%7 == 3 {
+			inputs = append(inputs, fmt.Sprintf("bad \xff %d", i))
+		} else {
+			inputs = append(inputs, fmt.Sprintf("message %d, 汪", i))
+		}
+	}
+	for _, workers := range []int{0, 1, 4, 100} {
+		outputs, errs := EncodeBatch(inputs, workers)
+		if len(outputs) != len(inputs) || len(errs) != len(inputs) {
+			t.Fatalf("%d workers: %d outputs, %d errors for %d inputs", workers, len(outputs), len(errs), len(inputs))
+		}
+		for i, in := range inputs {
+			if i%7 == 3 {
+				if !errors.Is(errs[i], errInvalidInput) || outputs[i] != "" {
+					t.Errorf("%d workers, input %d: %q, %v; want errInvalidInput", workers, i, outputs[i], errs[i])
+				}
+				continue
+			}
+			if errs[i] != nil || outputs[i] != mustEncode(t, in) {
+				t.Errorf("%d workers, input %d: %q, %v; want the encoding of %q", workers, i, outputs[i], errs[i], in)
+			}
+		}
+	}
+	for _, workers := range []int{0, 1, 8} {
+		if outputs, errs := EncodeBatch(nil, workers); len(outputs) != 0 || len(errs) != 0 {
+			t.Errorf("%d workers, no input: %q, %v", workers, outputs, errs)
+		}
+	}
+}
+
+func TestDecodeJSONLines(t *testing.T) {
+	good := mustEncode(t, `汪 "hi"`)
+	fields := strings.Fields(good)
+	bad := strings.Join(fields[:8], " ") + " bark " + strings.Join(fields[8:], " ")
+	in := good + "\n" + bad + "\n"
+	var out bytes.Buffer
+	if err := DecodeJSONLines(strings.NewReader(in), &out); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"line":1,"text":"汪 \"hi\""}` + "\n" + `{"line":2,"error":"unknown token: \"bark\""}` + "\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"container/list"
+	"errors"
+	"maps"
+	"sync"
+)
+
+// decodeCache is a fixed-size LRU of successful decode results keyed by the
+// input. It is safe for concurrent use.
+type decodeCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // front is most recent; values are *cacheEntry
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key string
+	res Result
+}
+
+func newDecodeCache(size int) *decodeCache {
+	return &decodeCache{size: size, order: list.New(), items: make(map[string]*list.Element, size)}
+}
+
+// WithCache keeps the results of the last size successful decodes, so the same
+// input decodes only once. Errors are not cached. A clone gets its own empty
+// cache, since its options may decode the same input differently.
+func WithCache(size int) Option {
+	return func(c *Codec) error {
+		if size <= 0 {
+			return errors.New("cache size must be positive")
+		}
+		c.cache = newDecodeCache(size)
+		return nil
+	}
+}
+
+func (dc *decodeCache) get(key string) (Result, bool) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	el, ok := dc.items[key]
+	if !ok {
+		return Result{}, false
+	}
+	dc.order.MoveToFront(el)
+	return copyResult(el.Value.(*cacheEntry).res), true
+}
+
+func (dc *decodeCache) put(key string, res Result) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if el, ok := dc.items[key]; ok {
+		dc.order.MoveToFront(el)
+		return
+	}
+	dc.items[key] = dc.order.PushFront(&cacheEntry{key: key, res: copyResult(res)})
+	if dc.order.Len() > dc.size {
+		oldest := dc.order.Back()
+		dc.order.Remove(oldest)
+		delete(dc.items, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// copyResult copies the mutable parts of res, so callers cannot change the cache.
+func copyResult(res Result) Result {
+	res.Warnings = append([]string(nil), res.Warnings...)
+	res.Metadata = maps.Clone(res.Metadata)
+	return res
+}
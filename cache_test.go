@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestDecodeCache(t *testing.T) {
+	c := mustCodec(t, WithCache(2), WithMetadata(nil))
+	enc := mustCodec(t, WithMetadata(map[string]string{"k": "v"}))
+	var speech []string
+	for _, text := range []string{"one", "two", "three"} {
+		s, err := enc.Encode(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		speech = append(speech, s)
+	}
+
+	res, err := c.DecodeDetailed(speech[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Metadata["k"] = "changed by the caller"
+	if again, err := c.DecodeDetailed(speech[0]); err != nil || again.Metadata["k"] != "v" {
+		t.Fatalf("cached result was changed through a returned one: %v, %v", again.Metadata, err)
+	}
+
+	// Looking speech[0] up again makes speech[1] the least recently used.
+	for _, s := range []string{speech[1], speech[0], speech[2]} {
+		if _, err := c.Decode(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := c.cache.items[speech[1]]; ok {
+		t.Error("least recently used entry was kept")
+	}
+	if _, ok := c.cache.items[speech[0]]; !ok {
+		t.Error("recently used entry was evicted")
+	}
+
+	if _, err := c.Decode("not dog speech"); err == nil {
+		t.Fatal("decoded garbage")
+	}
+	if _, ok := c.cache.items["not dog speech"]; ok {
+		t.Error("an error was cached")
+	}
+	if _, err := NewCodec(WithCache(0)); err == nil {
+		t.Error("WithCache accepted size 0")
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// zeroWidthDigits are the base-4 digits HideInCover writes, three per 6-bit
+// token id: zero-width space, non-joiner, joiner and word joiner.
+var zeroWidthDigits = [4]rune{'\u200B', '\u200C', '\u200D', '\u2060'}
+
+func zeroWidthDigit(r rune) (int, bool) {
+	for d, z := range zeroWidthDigits {
+		if z == r {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// HideInCover hides dog speech in cover text as invisible characters: each
+// token id becomes three zero-width characters placed after a rune of the
+// cover, left to right, and whatever does not fit goes at the end. The cover
+// reads the same, and RevealFromCover gets the dog speech back. The cover must
+// not contain those characters itself.
+func HideInCover(dogSpeech, cover string) (string, error) {
+	if strings.ContainsFunc(cover, func(r rune) bool { _, ok := zeroWidthDigit(r); return ok }) {
+		return "", errors.New("cover text already contains zero-width characters")
+	}
+	ids, err := DecodeToIDs(dogSpeech)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(cover) + len(ids)*3*3)
+	hide := func(id byte) {
+		sb.WriteRune(zeroWidthDigits[id>>4&3])
+		sb.WriteRune(zeroWidthDigits[id>>2&3])
+		sb.WriteRune(zeroWidthDigits[id&3])
+	}
+	for _, r := range cover {
+		sb.WriteRune(r)
+		if len(ids) > 0 {
+			hide(ids[0])
+			ids = ids[1:]
+		}
+	}
+	for _, id := range ids {
+		hide(id)
+	}
+	return sb.String(), nil
+}
+
+// RevealFromCover collects the zero-width characters in s, as written by
+// HideInCover, and returns the dog speech they spell. Everything else is cover.
+func RevealFromCover(s string) (string, error) {
+	var ids []byte
+	var id byte
+	n := 0
+	for _, r := range s {
+		d, ok := zeroWidthDigit(r)
+		if !ok {
+			continue
+		}
+		id = id<<2 | byte(d)
+		if n++; n%3 == 0 {
+			ids = append(ids, id)
+			id = 0
+		}
+	}
+	if n == 0 {
+		return "", errors.New("no hidden dog speech in the input")
+	}
+	if n%3 != 0 {
+		return "", fmt.Errorf("hidden sequence has %d zero-width characters, not a multiple of 3", n)
+	}
+	return EncodeFromIDs(ids)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func visibleText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if _, ok := zeroWidthDigit(r); ok {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func TestCamouflageRoundTrip(t *testing.T) {
+	speech := mustEncode(t, "hidden woof")
+	for _, cover := range []string{"", "hi", "a much longer cover text than there are tokens to hide in it, by far", "看不見的狗"} {
+		hidden, err := HideInCover(speech, cover)
+		if err != nil {
+			t.Fatalf("%q: %v", cover, err)
+		}
+		if visibleText(hidden) != cover {
+			t.Fatalf("%q: cover reads %q", cover, visibleText(hidden))
+		}
+		found, err := RevealFromCover(hidden)
+		if err != nil {
+			t.Fatalf("%q: %v", cover, err)
+		}
+		if got, err := Decode(found); err != nil || got != "hidden woof" {
+			t.Fatalf("%q: revealed %q, decoded %q, %v", cover, found, got, err)
+		}
+	}
+}
+
+func TestCamouflageRejects(t *testing.T) {
+	speech := mustEncode(t, "x")
+	if _, err := HideInCover(speech, "already\u200Bhidden"); err == nil {
+		t.Error("HideInCover accepted a cover with zero-width characters")
+	}
+	if _, err := RevealFromCover("plain text"); err == nil {
+		t.Error("RevealFromCover found something in plain text")
+	}
+	if _, err := RevealFromCover("a\u200B\u200Cb"); err == nil {
+		t.Error("RevealFromCover accepted two digits")
+	}
+}
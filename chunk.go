@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// Chunked streams are an alternative framing for payloads whose length is not
+// known up front. Each chunk is a 4-byte big-endian data length, a CRC-32
+// (IEEE) over that length, a CRC-32 over the data, the data itself and zero
+// bytes up to a multiple of 3. The multiple of 3 makes every chunk end on a
+// token boundary (3 bytes = 4 tokens), so a damaged token only ever spoils one
+// chunk. The length has its own checksum so that a damaged one is never
+// trusted: the decoder then searches forward, a token group at a time, for the
+// next header that checks out. A chunk with no data ends the stream. A chunked
+// stream is not a frame: Decode cannot read it, and ChunkDecoder cannot read a frame.
+const (
+	defaultChunkSize = 4096
+	maxChunkSize     = 1 << 24
+	chunkHeaderSize  = 12
+)
+
+// ChunkEncoder writes a chunked stream of dog speech to w.
+type ChunkEncoder struct {
+	tok  *Encoder // token writer; its size bookkeeping is unused
+	size int
+	buf  []byte
+	err  error
+}
+
+// NewChunkEncoder returns a ChunkEncoder that cuts the payload into chunks of
+// size bytes; size <= 0 selects 4096. Close must be called to end the stream.
+func NewChunkEncoder(w io.Writer, size int) *ChunkEncoder {
+	if size <= 0 {
+		size = defaultChunkSize
+	}
+	return &ChunkEncoder{
+		tok:  &Encoder{w: w, remaining: math.MaxInt64},
+		size: min(size, maxChunkSize),
+	}
+}
+
+// Write buffers p and writes every chunk that is complete.
+func (e *ChunkEncoder) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	e.buf = append(e.buf, p...)
+	for len(e.buf) >= e.size {
+		if err := e.writeChunk(e.buf[:e.size]); err != nil {
+			return 0, err
+		}
+		e.buf = e.buf[e.size:]
+	}
+	return len(p), nil
+}
+
+// Close writes the last partial chunk, if any, and the terminating empty chunk.
+func (e *ChunkEncoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	if len(e.buf) > 0 {
+		if err := e.writeChunk(e.buf); err != nil {
+			return err
+		}
+	}
+	if err := e.writeChunk(nil); err != nil {
+		return err
+	}
+	e.err = errors.New("encoder closed")
+	return nil
+}
+
+func (e *ChunkEncoder) writeChunk(data []byte) error {
+	var header [chunkHeaderSize]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(header[4:8], crc32.ChecksumIEEE(header[:4]))
+	binary.BigEndian.PutUint32(header[8:], crc32.ChecksumIEEE(data))
+
+	e.tok.push(header[:])
+	e.tok.push(data)
+	e.tok.push(make([]byte, chunkPadding(len(data))))
+	if err := e.tok.flush(); err != nil {
+		e.err = err
+		return err
+	}
+	return nil
+}
+
+// chunkPadding returns how many zero bytes follow n data bytes in a chunk.
+func chunkPadding(n int) int {
+	return (3 - (chunkHeaderSize+n)%3) % 3
+}
+
+// ChunkError reports a chunk whose header, checksum or tokens are wrong. Its
+// data is dropped; the ChunkDecoder that returned it can keep reading the next chunk.
+type ChunkError struct {
+	Chunk int // 0-based index of the damaged chunk
+	Err   error
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("chunk %d: %v", e.Chunk, e.Err)
+}
+
+func (e *ChunkError) Unwrap() error { return e.Err }
+
+// ErrChunkFraming reports that no chunk header could be found after a damaged
+// one before the input ended. Unlike *ChunkError it ends the stream: every
+// later Read returns it again.
+var ErrChunkFraming = errors.New("chunk framing lost")
+
+// headerGroups is how many 4-token groups a chunk header takes.
+const headerGroups = chunkHeaderSize / 3
+
+// ChunkDecoder reads a chunked stream written by ChunkEncoder and yields the
+// payload. Every chunk is checked before any of its bytes are returned.
+type ChunkDecoder struct {
+	src   *Decoder // token reader only
+	chunk int
+	out   []byte
+	err   error // sticky: io.EOF after the last chunk, or a stream-level failure
+
+	head   [chunkHeaderSize]byte // a header found while resynchronizing
+	resync bool                  // head holds a header next has not used yet
+}
+
+// NewChunkDecoder returns a ChunkDecoder reading dog speech from r.
+func NewChunkDecoder(r io.Reader) *ChunkDecoder {
+	return &ChunkDecoder{src: NewDecoder(r)}
+}
+
+// Read implements io.Reader. A *ChunkError is not sticky: the next Read goes
+// on with the following chunk. ErrChunkFraming and io.ErrUnexpectedEOF are.
+func (d *ChunkDecoder) Read(p []byte) (int, error) {
+	for len(d.out) == 0 && d.err == nil {
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	if len(d.out) > 0 {
+		n := copy(p, d.out)
+		d.out = d.out[n:]
+		return n, nil
+	}
+	return 0, d.err
+}
+
+// next reads one chunk into d.out.
+func (d *ChunkDecoder) next() error {
+	head := d.head
+	if d.resync {
+		d.resync = false
+	} else {
+		var bad uint8
+		var err error
+		if head, bad, err = d.readHeader(); err != nil {
+			return err
+		}
+		if _, ok := headerLength(head, bad); !ok {
+			// The length can't be trusted, so neither can where the chunk ends.
+			index := d.chunk
+			d.chunk++
+			if err := d.findHeader(head, bad); err != nil {
+				return err
+			}
+			return &ChunkError{Chunk: index, Err: errors.New("damaged chunk header")}
+		}
+	}
+	n, _ := headerLength(head, 0)
+
+	index := d.chunk
+	d.chunk++
+	body, bad, err := d.readGroups((n + chunkPadding(n)) / 3)
+	if err != nil {
+		return err
+	}
+	data := body[:n]
+	switch {
+	case bad:
+		return &ChunkError{Chunk: index, Err: errors.New("unknown token")}
+	case crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(head[8:]):
+		return &ChunkError{Chunk: index, Err: errors.New("checksum mismatch")}
+	case n == 0:
+		d.err = io.EOF
+		return nil
+	}
+	d.out = data
+	return nil
+}
+
+// headerLength returns the data length in head if its checksum holds and no
+// group of it (bit i of bad for group i) had an unknown token.
+func headerLength(head [chunkHeaderSize]byte, bad uint8) (int, bool) {
+	n := binary.BigEndian.Uint32(head[:4])
+	if bad != 0 || n > maxChunkSize || crc32.ChecksumIEEE(head[:4]) != binary.BigEndian.Uint32(head[4:8]) {
+		return 0, false
+	}
+	return int(n), true
+}
+
+// findHeader slides a header-sized window one group at a time from the damaged
+// header head until it holds a valid header, and leaves that for next. Chunks
+// start on group boundaries, so the next intact header is found, lost only with
+// the chance of a CRC-32 collision.
+func (d *ChunkDecoder) findHeader(head [chunkHeaderSize]byte, bad uint8) error {
+	for {
+		g, gbad, err := d.readGroup()
+		if err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				d.err = fmt.Errorf("chunk %d: no chunk header found after damage: %w", d.chunk, ErrChunkFraming)
+				return d.err
+			}
+			return err
+		}
+		copy(head[:], head[3:])
+		copy(head[chunkHeaderSize-3:], g[:])
+		bad >>= 1
+		if gbad {
+			bad |= 1 << (headerGroups - 1)
+		}
+		if _, ok := headerLength(head, bad); ok {
+			d.head, d.resync = head, true
+			return nil
+		}
+	}
+}
+
+// readHeader reads a chunk header, setting bit i of bad when group i of it
+// held an unknown token.
+func (d *ChunkDecoder) readHeader() (head [chunkHeaderSize]byte, bad uint8, err error) {
+	for i := range headerGroups {
+		g, gbad, err := d.readGroup()
+		if err != nil {
+			return head, 0, err
+		}
+		copy(head[3*i:], g[:])
+		if gbad {
+			bad |= 1 << i
+		}
+	}
+	return head, bad, nil
+}
+
+// readGroups reads k groups of 4 tokens and returns their 3k bytes.
+func (d *ChunkDecoder) readGroups(k int) (b []byte, bad bool, err error) {
+	b = make([]byte, 0, 3*k)
+	for range k {
+		g, gbad, err := d.readGroup()
+		if err != nil {
+			return nil, false, err
+		}
+		b = append(b, g[:]...)
+		bad = bad || gbad
+	}
+	return b, bad, nil
+}
+
+// readGroup reads 4 tokens and returns their 3 bytes. An unknown token is read
+// as id 0 and reported through bad, so the chunk still ends in the right place.
+func (d *ChunkDecoder) readGroup() (b [3]byte, bad bool, err error) {
+	var group uint32
+	for range 4 {
+		tok, err := d.src.nextToken()
+		if err != nil {
+			d.err = err
+			return b, false, err
+		}
+		if tok == nil {
+			d.err = fmt.Errorf("stream ends inside chunk %d: %w", d.chunk, io.ErrUnexpectedEOF)
+			return b, false, d.err
+		}
+		id, ok := lookupToken(toNFC(string(tok)))
+		bad = bad || !ok
+		group = group<<6 | uint32(id)
+	}
+	return [3]byte{byte(group >> 16), byte(group >> 8), byte(group)}, bad, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func chunkEncode(t *testing.T, payload []byte, size int) string {
+	t.Helper()
+	var sb strings.Builder
+	enc := NewChunkEncoder(&sb, size)
+	if _, err := enc.Write(payload); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return sb.String()
+}
+
+func TestChunkRoundTrip(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 3, 7, 100, 1000} {
+		payload := bytes.Repeat([]byte("woof!"), n)[:n]
+		got, err := io.ReadAll(NewChunkDecoder(strings.NewReader(chunkEncode(t, payload, 16))))
+		if err != nil {
+			t.Fatalf("%d bytes: %v", n, err)
+		}
+		if !bytes.Equal(got, payload) {
+			t.Fatalf("%d bytes: got %q", n, got)
+		}
+	}
+}
+
+// readChunks reads a chunked stream to its end, skipping *ChunkError, and
+// returns the data, the damaged chunk indexes and the error that ended it.
+func readChunks(in string) (got []byte, damaged []int, err error) {
+	d := NewChunkDecoder(strings.NewReader(in))
+	buf := make([]byte, 64)
+	for {
+		n, err := d.Read(buf)
+		got = append(got, buf[:n]...)
+		var ce *ChunkError
+		switch {
+		case errors.As(err, &ce):
+			damaged = append(damaged, ce.Chunk)
+		case err != nil:
+			return got, damaged, err
+		}
+	}
+}
+
+// bump replaces field i with the next token in the codebook.
+func bump(t *testing.T, fields []string, i int) string {
+	t.Helper()
+	cp := append([]string(nil), fields...)
+	cp[i] = codebook[(int(mustID(t, cp[i]))+1)%len(codebook)]
+	return strings.Join(cp, " ")
+}
+
+// A chunk of 8 bytes is 12+8+1 = 21 bytes, 28 tokens; the header is its first 16.
+const chunk8Tokens = 28
+
+func TestChunkErrorSkipsOneChunk(t *testing.T) {
+	fields := strings.Fields(chunkEncode(t, []byte("aaaaaaaabbbbbbbbcccccccc"), 8))
+	got, damaged, err := readChunks(bump(t, fields, chunk8Tokens+20))
+	if err != io.EOF || len(damaged) != 1 || damaged[0] != 1 || string(got) != "aaaaaaaacccccccc" {
+		t.Fatalf("got %q, damaged %v, %v", got, damaged, err)
+	}
+}
+
+func TestChunkBadLengthResyncs(t *testing.T) {
+	fields := strings.Fields(chunkEncode(t, []byte("aaaaaaaabbbbbbbbccccccccdddddddd"), 8))
+	// Tokens 0-5 of a chunk carry its length (a bump of token 5 keeps it in
+	// range), 5-10 the length's checksum and 10-15 the data checksum.
+	for _, tok := range []int{0, 5, 7, 12, 15} {
+		got, damaged, err := readChunks(bump(t, fields, chunk8Tokens+tok))
+		if err != io.EOF || len(damaged) != 1 || damaged[0] != 1 || string(got) != "aaaaaaaaccccccccdddddddd" {
+			t.Errorf("header token %d damaged: got %q, damaged %v, %v", tok, got, damaged, err)
+		}
+	}
+}
+
+func TestChunkLostFramingIsSticky(t *testing.T) {
+	fields := strings.Fields(chunkEncode(t, []byte("hello"), 0))
+	// Damage the terminating chunk's header: there is no header after it.
+	d := NewChunkDecoder(strings.NewReader(bump(t, fields, len(fields)-16)))
+	got, err := io.ReadAll(d)
+	if string(got) != "hello" || !errors.Is(err, ErrChunkFraming) {
+		t.Fatalf("got %q, %v; want the data and ErrChunkFraming", got, err)
+	}
+	var ce *ChunkError
+	if errors.As(err, &ce) {
+		t.Fatalf("framing loss reported as a recoverable *ChunkError: %v", err)
+	}
+	for range 3 {
+		if n, err := d.Read(make([]byte, 8)); n != 0 || !errors.Is(err, ErrChunkFraming) {
+			t.Fatalf("Read after framing loss: %d, %v", n, err)
+		}
+	}
+}
+
+func mustID(t *testing.T, tok string) byte {
+	t.Helper()
+	id, ok := lookupToken(tok)
+	if !ok {
+		t.Fatalf("not a token: %q", tok)
+	}
+	return id
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardWriter copies text to the system clipboard. It is a variable so
+// callers without a desktop, or tests, can replace it.
+var clipboardWriter = copyToClipboard
+
+// clipboardCommands lists, per GOOS, the helper programs tried in order.
+var clipboardCommands = map[string][][]string{
+	"darwin":  {{"pbcopy"}},
+	"windows": {{"clip"}},
+	"linux": {
+		{"wl-copy"},
+		{"xclip", "-selection", "clipboard"},
+		{"xsel", "--clipboard", "--input"},
+	},
+}
+
+// copyToClipboard pipes text into the first clipboard helper found on PATH.
+func copyToClipboard(text string) error {
+	for _, args := range clipboardCommands[runtime.GOOS] {
+		path, err := exec.LookPath(args[0])
+		if err != nil {
+			continue
+		}
+		cmd := exec.Command(path, args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		return cmd.Run()
+	}
+	return errors.New("no clipboard helper found (tried pbcopy, clip, wl-copy, xclip, xsel)")
+}
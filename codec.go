@@ -0,0 +1,284 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Codec bundles encoding options. The codebook tables are never modified, so
+// every Codec, including clones, shares them.
+type Codec struct {
+	separator string
+	dict      *dictionary // nil without WithDictionary
+	meta      map[string]string
+	hasMeta   bool   // frames start with metadata (WithMetadata)
+	lang      string // BCP 47 tag stored under LanguageKey (WithLanguage)
+	stamp     bool   // store the encode time under TimeKey (WithTimestamp)
+	label     string // stored under LabelKey with stamp, unless empty
+	cache     *decodeCache
+	nonce     int // random bytes in front of each frame's payload (WithNonce); 0 for none
+
+	deterministic bool // nonce bytes are zeros (WithDeterministic)
+}
+
+// Option configures a Codec in NewCodec or Clone.
+type Option func(*Codec) error
+
+// WithSeparator puts sep between tokens instead of a single space. sep must not
+// share any character with a token, so decoding can find it again.
+func WithSeparator(sep string) Option {
+	return func(c *Codec) error {
+		if err := checkSeparator(sep, nil); err != nil {
+			return err
+		}
+		c.separator = sep
+		return nil
+	}
+}
+
+// checkSeparator reports whether sep can stand between tokens: it must be
+// non-empty and share no character with any token as r renders it (r may be nil).
+func checkSeparator(sep string, r Renderer) error {
+	if sep == "" {
+		return errors.New("separator must not be empty")
+	}
+	for _, token := range codebook {
+		if r != nil {
+			token = r.Render(token)
+		}
+		if strings.ContainsAny(token, sep) {
+			return fmt.Errorf("separator %q shares a character with token %q", sep, token)
+		}
+	}
+	return nil
+}
+
+// NewCodec returns a Codec with the default options overridden by opts.
+func NewCodec(opts ...Option) (*Codec, error) {
+	c := &Codec{separator: " "}
+	return c.apply(opts)
+}
+
+// Clone returns a copy of c with opts applied; c itself is left unchanged. The
+// copy gets its own empty cache. Unlike a plain copy it can fail, because opts
+// are checked as in NewCodec, so it returns an error alongside the *Codec.
+func (c *Codec) Clone(opts ...Option) (*Codec, error) {
+	cp := *c
+	if c.cache != nil {
+		cp.cache = newDecodeCache(c.cache.size)
+	}
+	return cp.apply(opts)
+}
+
+func (c *Codec) apply(opts []Option) (*Codec, error) {
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Encode is like the package-level Encode, using the Codec's options.
+func (c *Codec) Encode(input string) (string, error) {
+	input = toNFC(input)
+	if !utf8.ValidString(input) {
+		return "", errInvalidInput
+	}
+	var payload []byte
+	if c.nonce > 0 {
+		payload = appendNonce(payload, c.nonce, c.deterministic)
+	}
+	if c.hasMeta {
+		meta := c.meta
+		if c.lang != "" || c.stamp {
+			meta = maps.Clone(c.meta)
+			if meta == nil {
+				meta = make(map[string]string, 3)
+			}
+		}
+		if c.lang != "" {
+			meta[LanguageKey] = c.lang
+		}
+		if c.stamp {
+			var t time.Time
+			if !c.deterministic {
+				t = timeNow()
+			}
+			meta[TimeKey] = t.UTC().Format(time.RFC3339Nano)
+			if c.label != "" {
+				meta[LabelKey] = c.label
+			}
+		}
+		payload = appendMetadata(payload, meta)
+	}
+	if c.dict != nil {
+		body, err := c.dict.substitute(input)
+		if err != nil {
+			return "", err
+		}
+		payload = append(payload, body...)
+	} else {
+		payload = append(payload, input...)
+	}
+	out := EncodeBytes(payload)
+	if c.separator != " " {
+		out = strings.ReplaceAll(out, " ", c.separator)
+	}
+	return out, nil
+}
+
+// metaMapBytes is roughly what a small metadata map allocates before its keys
+// and values: the map header and its first group of slots.
+const metaMapBytes = 320
+
+// EstimateMemory roughly estimates the bytes c allocates to encode inputBytes
+// of text, and to decode the dog speech that encoding produces, counting the
+// nonce, metadata and dictionary hash that go into the payload. It is
+// arithmetic on the format and the current implementation, not a measurement.
+// It assumes the text is already NFC, as most is (otherwise encode copies it
+// once more), and that no dictionary phrase in it was substituted.
+func (c *Codec) EstimateMemory(inputBytes int) (encodeBytes, decodeBytes int) {
+	inputBytes = max(inputBytes, 0)
+	payload, metaBytes := inputBytes, 0
+	if c.nonce > 0 {
+		payload += 1 + c.nonce
+	}
+	if c.hasMeta {
+		metaBytes = c.metadataBytes()
+		payload += metaBytes
+	}
+	if c.dict != nil {
+		payload += len(c.dict.hash)
+	}
+	tokens := tokensFor(payload)
+	tokenBytes := 0
+	for _, tok := range codebook {
+		tokenBytes += len(tok)
+	}
+	speech := tokens * (tokenBytes/len(codebook) + len(c.separator))
+
+	// The payload, appended together, with the nonce drawn into a buffer of its
+	// own and, with a dictionary, the body it is appended from; the result,
+	// preallocated at 8 bytes per token; and the separator replaced into a copy
+	// of it. The framed buffer comes from scratchPool unless it is over
+	// maxPooledScratch.
+	encodeBytes = payload + 8*tokens
+	if c.nonce > 0 {
+		encodeBytes += c.nonce
+	}
+	if c.dict != nil {
+		encodeBytes += payload
+	}
+	if 4+payload > maxPooledScratch {
+		encodeBytes += 4 + payload
+	}
+	if c.separator != " " {
+		encodeBytes += speech
+	}
+	if len(c.meta) > 0 && (c.lang != "" || c.stamp) {
+		encodeBytes += metaMapBytes // the WithMetadata pairs cloned to add to
+	}
+	// decodeTokens allocates the payload at its declared size, and the text is
+	// copied out of it, once more by a dictionary. Speech from Encode is NFC
+	// already, so it is not copied, unless a separator is replaced. Metadata
+	// costs its map and a copy of its keys and values.
+	decodeBytes = payload + inputBytes
+	if c.dict != nil {
+		decodeBytes += inputBytes
+	}
+	if c.separator != " " {
+		decodeBytes += speech
+	}
+	if c.hasMeta {
+		decodeBytes += metaMapBytes + metaBytes
+	}
+	return encodeBytes, decodeBytes
+}
+
+// metadataBytes is the size of the metadata Encode would write now.
+func (c *Codec) metadataBytes() int {
+	meta := maps.Clone(c.meta)
+	if meta == nil {
+		meta = map[string]string{}
+	}
+	if c.lang != "" {
+		meta[LanguageKey] = c.lang
+	}
+	if c.stamp {
+		meta[TimeKey] = timeNow().UTC().Format(time.RFC3339Nano)
+		if c.label != "" {
+			meta[LabelKey] = c.label
+		}
+	}
+	return len(appendMetadata(nil, meta))
+}
+
+// Decode is like the package-level Decode, using the Codec's options.
+func (c *Codec) Decode(dogSpeech string) (string, error) {
+	res, err := c.DecodeDetailed(dogSpeech)
+	if err != nil {
+		return "", err
+	}
+	return res.Text, nil
+}
+
+// DecodeDetailed decodes like Decode and fills in Text, TokenCount,
+// PayloadBytes and, with WithMetadata, Metadata.
+func (c *Codec) DecodeDetailed(dogSpeech string) (Result, error) {
+	if c.cache == nil {
+		return c.decodeDetailed(dogSpeech)
+	}
+	if res, ok := c.cache.get(dogSpeech); ok {
+		return res, nil
+	}
+	res, err := c.decodeDetailed(dogSpeech)
+	if err == nil {
+		c.cache.put(dogSpeech, res)
+	}
+	return res, err
+}
+
+func (c *Codec) decodeDetailed(dogSpeech string) (Result, error) {
+	if c.separator != " " {
+		dogSpeech = strings.ReplaceAll(dogSpeech, c.separator, " ")
+	}
+	payload, err := DecodeBytes(dogSpeech)
+	if err != nil {
+		return Result{}, err
+	}
+	tokens, _ := trimPadToken(dogSpeech)
+	res := Result{TokenCount: countTokens(tokens), PayloadBytes: len(payload)}
+	body := payload
+	if c.nonce > 0 {
+		if body, err = cutNonce(body); err != nil {
+			return Result{}, err
+		}
+	}
+	if c.hasMeta {
+		if res.Metadata, body, err = cutMetadata(body); err != nil {
+			return Result{}, err
+		}
+		res.Language = res.Metadata[LanguageKey]
+		res.Label = res.Metadata[LabelKey]
+		if v, ok := res.Metadata[TimeKey]; ok {
+			// A TimeKey from WithMetadata need not be a time; then it stays in Metadata only.
+			res.Time, _ = time.Parse(time.RFC3339Nano, v)
+		}
+	}
+	text := string(body)
+	if c.dict != nil {
+		if text, err = c.dict.expand(body); err != nil {
+			return Result{}, err
+		}
+	}
+	if !utf8.ValidString(text) {
+		return Result{}, errInvalidPayload
+	}
+	res.Text = text
+	return res, nil
+}
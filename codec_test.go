@@ -0,0 +1,102 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func mustCodec(t *testing.T, opts ...Option) *Codec {
+	t.Helper()
+	c, err := NewCodec(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCloneIsIndependent(t *testing.T) {
+	base := mustCodec(t, WithCache(4))
+	clone, err := base.Clone(WithSeparator("|"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fromBase, err := base.Encode("woof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromClone, err := clone.Encode("woof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(fromBase, "|") || !strings.Contains(fromClone, "|") {
+		t.Fatalf("base wrote %q, clone wrote %q", fromBase, fromClone)
+	}
+	if fromClone != strings.ReplaceAll(fromBase, " ", "|") {
+		t.Fatalf("clone encodes %q, want the base's tokens joined by |", fromClone)
+	}
+
+	if got, err := clone.Decode(fromClone); err != nil || got != "woof" {
+		t.Fatalf("clone.Decode = %q, %v", got, err)
+	}
+	if clone.cache == base.cache {
+		t.Fatal("clone shares the base's cache")
+	}
+	if n := len(base.cache.items); n != 0 {
+		t.Fatalf("decoding with the clone left %d entries in the base's cache", n)
+	}
+
+	if _, err := base.Clone(WithSeparator("")); err == nil {
+		t.Error("Clone accepted an empty separator")
+	}
+	if base.separator != " " {
+		t.Errorf("a failed Clone changed the base's separator to %q", base.separator)
+	}
+}
+
+// allocatedPerRun is the bytes f allocates per call, averaged over runs calls
+// after a warm-up call.
+func allocatedPerRun(runs int, f func()) int {
+	f()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for range runs {
+		f()
+	}
+	runtime.ReadMemStats(&after)
+	return int(after.TotalAlloc-before.TotalAlloc) / runs
+}
+
+func TestEstimateMemory(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations differ under -race")
+	}
+	codecs := map[string]*Codec{
+		"plain":      mustCodec(t),
+		"nonce+meta": mustCodec(t, WithNonce(16), WithMetadata(map[string]string{"k": "value"}), WithLanguage("en")),
+		"dictionary": mustCodec(t, WithDictionary(map[string]string{"x1": "good dog"})),
+		"separator":  mustCodec(t, WithSeparator("|")),
+	}
+	// Within a fifth of the measurement, or 64 bytes for the size classes small
+	// allocations are rounded up to.
+	near := func(est, got int) bool { return est-got <= max(got/5, 64) && got-est <= max(got/5, 64) }
+	for name, c := range codecs {
+		for _, n := range []int{10, 1000, 100000} {
+			text := strings.Repeat("a", n)
+			speech, err := c.Encode(text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			enc := allocatedPerRun(20, func() { c.Encode(text) })
+			dec := allocatedPerRun(20, func() { c.Decode(speech) })
+			estEnc, estDec := c.EstimateMemory(n)
+			if !near(estEnc, enc) {
+				t.Errorf("%s, %d bytes: encode estimate %d, measured %d", name, n, estEnc, enc)
+			}
+			if !near(estDec, dec) {
+				t.Errorf("%s, %d bytes: decode estimate %d, measured %d", name, n, estDec, dec)
+			}
+		}
+	}
+}
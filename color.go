@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+)
+
+// coreColors are the ANSI foreground colors for the 8 cores, in core order.
+var coreColors = [8]string{"31", "32", "33", "34", "35", "36", "91", "94"}
+
+// colorizeTokens wraps every token of out in the ANSI color of its core, keeping
+// separators and line breaks. id maps a field as written (styled, prettified,
+// run-compacted) to its codebook id; fields it does not know stay uncolored.
+func colorizeTokens(out string, id func(field string) (byte, bool)) string {
+	var sb strings.Builder
+	start := 0
+	for i := 0; i <= len(out); i++ {
+		if i < len(out) && out[i] != ' ' && out[i] != '\n' {
+			continue
+		}
+		field := out[start:i]
+		if v, ok := id(field); ok && field != "" {
+			sb.WriteString("\x1b[" + coreColors[int(v)/len(tones)] + "m" + field + "\x1b[0m")
+		} else {
+			sb.WriteString(field)
+		}
+		if i < len(out) {
+			sb.WriteByte(out[i])
+		}
+		start = i + 1
+	}
+	return sb.String()
+}
+
+// fieldID finds the token id of one output field, undoing --pretty, --rle and
+// the renderer (style or preset) when r is not nil.
+func fieldID(field string, r Renderer) (byte, bool) {
+	field = strings.TrimSpace(Unprettify(field))
+	if r != nil {
+		field = strings.TrimSpace(r.Normalize(field))
+	}
+	field, _, _ = strings.Cut(field, runMark)
+	id, ok := reverseTable[field]
+	return id, ok
+}
+
+// StripColors removes the ANSI color sequences colorizeTokens adds, so colored
+// output piped back into decode still decodes.
+func StripColors(s string) string {
+	if !strings.Contains(s, "\x1b[") {
+		return s
+	}
+	var sb strings.Builder
+	for {
+		i := strings.Index(s, "\x1b[")
+		if i < 0 {
+			break
+		}
+		sb.WriteString(s[:i])
+		j := i + 2
+		for j < len(s) && (s[j] == ';' || (s[j] >= '0' && s[j] <= '9')) {
+			j++
+		}
+		if j < len(s) && s[j] == 'm' {
+			j++
+		}
+		s = s[j:]
+	}
+	sb.WriteString(s)
+	return sb.String()
+}
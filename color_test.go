@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestColorCLI(t *testing.T) {
+	speech := mustEncode(t, "colors")
+	fields := strings.Fields(speech)
+	colored := make([]string, len(fields))
+	for i, f := range fields {
+		colored[i] = "\x1b[" + coreColors[int(reverseTable[f])/len(tones)] + "m" + f + "\x1b[0m"
+	}
+	want := strings.Join(colored, " ") + "\n"
+
+	// --color takes its value after "=": with a space it would be read as input.
+	out, _, err := runCLI(t, "", "encode", "--color=always", "colors")
+	if err != nil || out != want {
+		t.Fatalf("--color always: %q, %v; want %q", out, err, want)
+	}
+	if got, _, err := runCLI(t, out, "decode"); err != nil || got != "colors\n" {
+		t.Errorf("decode of colored output: %q, %v", got, err)
+	}
+	// Not a terminal, so auto (also what --color alone means) stays plain.
+	for _, args := range [][]string{{"--color=never"}, {"--color=auto"}, {"--color"}, nil} {
+		out, _, err := runCLI(t, "", append(append([]string{"encode"}, args...), "colors")...)
+		if err != nil || out != speech+"\n" {
+			t.Errorf("encode %v: %q, %v", args, out, err)
+		}
+	}
+	if _, _, err := runCLI(t, "", "encode", "--color=rainbow", "colors"); err == nil || !strings.Contains(err.Error(), `unknown --color "rainbow"`) {
+		t.Errorf("--color rainbow: %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// envPrefix starts the environment variables that set flags, e.g.
+// WOOFWOOF_ARMOR=true for --armor or WOOFWOOF_PRESET=cat for --preset.
+const envPrefix = "WOOFWOOF_"
+
+// configPath returns the config file to load: $WOOFWOOF_CONFIG, or
+// ~/.woofwoof.json.
+func configPath() string {
+	if p := os.Getenv(envPrefix + "CONFIG"); p != "" {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".woofwoof.json")
+}
+
+// loadConfig reads a JSON config file of flag defaults. Top-level keys are flag
+// names for every command; an object under a command name ("encode", "decode",
+// ...) holds flags for that command only:
+//
+//	{"verbose": 1, "encode": {"armor": true, "preset": "cat"}}
+//
+// A missing file is no config.
+func loadConfig(path string) (map[string]json.RawMessage, error) {
+	if path == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, readError("config "+path, err)
+	}
+	var cfg map[string]json.RawMessage
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// applyDefaults sets every flag of cmd that was not given on the command line
+// from the environment, then from cfg, so the precedence is flags > env >
+// config > built-in defaults.
+func applyDefaults(cmd *cobra.Command, cfg map[string]json.RawMessage) error {
+	values := map[string]json.RawMessage{}
+	for key, raw := range cfg {
+		if isJSONObject(raw) {
+			if cmd.Root().CommandPath() == cmd.CommandPath() || key != cmd.Name() {
+				continue
+			}
+			var section map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &section); err != nil {
+				return fmt.Errorf("config section %q: %w", key, err)
+			}
+			for k, v := range section {
+				if cmd.Flags().Lookup(k) == nil {
+					return fmt.Errorf("config: %s has no --%s flag", cmd.Name(), k)
+				}
+				values[k] = v
+			}
+			continue
+		}
+		if cmd.Flags().Lookup(key) == nil {
+			if !anyCommandHasFlag(cmd.Root(), key) {
+				return fmt.Errorf("config: unknown option %q", key)
+			}
+			continue // another command's flag, like --mode or encode's --armor
+		}
+		if _, ok := values[key]; !ok {
+			values[key] = raw
+		}
+	}
+
+	var err error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || f.Name == "help" {
+			return
+		}
+		env := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if v, ok := os.LookupEnv(env); ok {
+			if e := cmd.Flags().Set(f.Name, v); e != nil {
+				err = fmt.Errorf("%s: %w", env, e)
+			}
+			return
+		}
+		raw, ok := values[f.Name]
+		if !ok {
+			return
+		}
+		v := string(bytes.TrimSpace(raw))
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			v = s
+		}
+		if e := cmd.Flags().Set(f.Name, v); e != nil {
+			err = fmt.Errorf("config option %q: %w", f.Name, e)
+		}
+	})
+	return err
+}
+
+// anyCommandHasFlag reports whether cmd or any command under it has the flag
+// name.
+func anyCommandHasFlag(cmd *cobra.Command, name string) bool {
+	if cmd.Flags().Lookup(name) != nil || cmd.PersistentFlags().Lookup(name) != nil {
+		return true
+	}
+	for _, c := range cmd.Commands() {
+		if anyCommandHasFlag(c, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isJSONObject reports whether raw holds a JSON object.
+func isJSONObject(raw json.RawMessage) bool {
+	raw = bytes.TrimSpace(raw)
+	return len(raw) > 0 && raw[0] == '{'
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, json string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "woofwoof.json")
+	if err := os.WriteFile(path, []byte(json), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(envPrefix+"CONFIG", path)
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	writeConfig(t, `{"armor": true, "rle": true, "encode": {"pad-token": true}}`)
+	armored := func(out string) bool { return strings.HasPrefix(out, armorBegin) }
+
+	out, _, err := runCLI(t, "", "encode", "hi")
+	if err != nil || !armored(out) || !strings.Contains(out, PadToken) {
+		t.Fatalf("config only: %q, %v", out, err)
+	}
+
+	t.Setenv(envPrefix+"ARMOR", "false")
+	if out, _, err = runCLI(t, "", "encode", "hi"); err != nil || armored(out) {
+		t.Fatalf("env over config: %q, %v", out, err)
+	}
+	if out, _, err = runCLI(t, "", "encode", "--armor", "hi"); err != nil || !armored(out) {
+		t.Fatalf("flag over env: %q, %v", out, err)
+	}
+
+	// armor and rle are encode flags; decode must not choke on them.
+	if out, _, err = runCLI(t, "", "decode", mustEncode(t, "hi")); err != nil || out != "hi\n" {
+		t.Fatalf("decode with encode-only config keys: %q, %v", out, err)
+	}
+}
+
+func TestConfigUnknownOption(t *testing.T) {
+	writeConfig(t, `{"no-such-flag": 1}`)
+	if _, _, err := runCLI(t, "", "decode", mustEncode(t, "hi")); err == nil || !strings.Contains(err.Error(), "unknown option") {
+		t.Fatalf("got %v, want the unknown option rejected", err)
+	}
+	writeConfig(t, `{"decode": {"armor": true}}`)
+	if _, _, err := runCLI(t, "", "decode", mustEncode(t, "hi")); err == nil || !strings.Contains(err.Error(), "no --armor flag") {
+		t.Fatalf("got %v, want decode's section to reject --armor", err)
+	}
+}
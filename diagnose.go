@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"unicode/utf8"
+)
+
+// Diagnostics is everything Diagnose could work out about a token stream.
+type Diagnostics struct {
+	TokenCount     int    // whitespace-separated fields, not counting a pad token
+	FirstUnknown   int    // index of the first field that is not a token, or -1
+	UnknownToken   string // that field
+	HeaderHex      string // the 4 length-header bytes in hex, or "" if too short
+	DeclaredLength int    // payload bytes the header declares, or -1 without a header
+	AvailableBytes int    // payload bytes the tokens before FirstUnknown carry
+	Complete       bool   // AvailableBytes covers DeclaredLength
+	TrailingTokens int    // tokens after the end of the frame
+	PaddingZero    bool   // the unused bits of the frame's last token are zero
+	UTF8Valid      bool   // the payload (up to DeclaredLength) is valid UTF-8
+	IncompleteRune bool   // a truncated payload ends inside a UTF-8 sequence
+	HeaderMissing  bool   // fewer than 6 usable tokens, so there is no header
+	Padded         bool   // the input ends with PadToken
+}
+
+// Diagnose inspects dogSpeech without failing: every check it can make is
+// reported in the result. Tokens are decoded up to the first unknown one.
+func Diagnose(dogSpeech string) Diagnostics {
+	d := Diagnostics{FirstUnknown: -1, DeclaredLength: -1}
+	s, padded := trimPadToken(toNFC(dogSpeech))
+	d.Padded = padded
+	var ids []byte
+	for tok, rest := nextToken(s); tok != ""; tok, rest = nextToken(rest) {
+		if id, ok := lookupToken(tok); ok && d.FirstUnknown < 0 {
+			ids = append(ids, id)
+		} else if d.FirstUnknown < 0 {
+			d.FirstUnknown, d.UnknownToken = d.TokenCount, tok
+		}
+		d.TokenCount++
+	}
+
+	bytesOut := packIDs(ids)
+	if len(bytesOut) < 4 {
+		d.HeaderMissing = true
+		return d
+	}
+	d.HeaderHex = hex.EncodeToString(bytesOut[:4])
+	n := int(binary.BigEndian.Uint32(bytesOut[:4]))
+	d.DeclaredLength = n
+	d.AvailableBytes = len(bytesOut) - 4
+	d.Complete = d.AvailableBytes >= n
+
+	payload := bytesOut[4:]
+	if d.Complete {
+		payload = payload[:n]
+		frame := tokensFor(n)
+		d.TrailingTokens = len(ids) - frame
+		padBits := uint(frame*6 - (4+n)*8)
+		d.PaddingZero = ids[frame-1]&(1<<padBits-1) == 0
+	} else {
+		for cut := len(payload) - 1; cut >= 0 && cut >= len(payload)-utf8.UTFMax+1; cut-- {
+			if utf8.RuneStart(payload[cut]) {
+				d.IncompleteRune = !utf8.FullRune(payload[cut:])
+				if d.IncompleteRune {
+					payload = payload[:cut]
+				}
+				break
+			}
+		}
+	}
+	d.UTF8Valid = utf8.Valid(payload)
+	return d
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiagnose(t *testing.T) {
+	speech := mustEncode(t, "diagnose me")
+	fields := strings.Fields(speech)
+	n := len(fields)
+	tests := []struct {
+		name  string
+		input string
+		want  Diagnostics
+	}{
+		{"valid", speech, Diagnostics{
+			TokenCount: n, FirstUnknown: -1, HeaderHex: "0000000b", DeclaredLength: 11, AvailableBytes: 11,
+			Complete: true, PaddingZero: true, UTF8Valid: true,
+		}},
+		{"truncated", strings.Join(fields[:n-4], " "), Diagnostics{
+			TokenCount: n - 4, FirstUnknown: -1, HeaderHex: "0000000b", DeclaredLength: 11, AvailableBytes: 8,
+			UTF8Valid: true,
+		}},
+		{"empty", "", Diagnostics{FirstUnknown: -1, DeclaredLength: -1, HeaderMissing: true}},
+		{"unknown token", strings.Join(fields[:8], " ") + " bark " + strings.Join(fields[8:], " "), Diagnostics{
+			TokenCount: n + 1, FirstUnknown: 8, UnknownToken: "bark", HeaderHex: "0000000b", DeclaredLength: 11, AvailableBytes: 2,
+			UTF8Valid: true,
+		}},
+		{"pad token", speech + " " + PadToken, Diagnostics{
+			TokenCount: n, FirstUnknown: -1, HeaderHex: "0000000b", DeclaredLength: 11, AvailableBytes: 11,
+			Complete: true, PaddingZero: true, UTF8Valid: true, Padded: true,
+		}},
+		{"trailing tokens", speech + " " + fields[0] + " " + fields[1], Diagnostics{
+			TokenCount: n + 2, FirstUnknown: -1, HeaderHex: "0000000b", DeclaredLength: 11, AvailableBytes: 12,
+			Complete: true, TrailingTokens: 2, PaddingZero: true, UTF8Valid: true,
+		}},
+	}
+	for _, tt := range tests {
+		if got := Diagnose(tt.input); got != tt.want {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// dictMark delimits a dictionary code in the substituted text, as in "\x1agm\x1a".
+// Text that already contains it cannot be encoded with a dictionary.
+const dictMark = "\x1a"
+
+// dictionary replaces common phrases with short codes before encoding.
+type dictionary struct {
+	phrases map[string]string // code -> phrase
+	hash    [4]byte           // identifies the dictionary; precedes the substituted text
+	encode  *strings.Replacer
+}
+
+// WithDictionary substitutes each phrase (a key of dict) with its code (the
+// value) before encoding, and back after decoding. The first 4 bytes of a
+// SHA-256 over the dictionary precede the text in the frame, so decoding with
+// a different dictionary fails instead of producing wrong text.
+func WithDictionary(dict map[string]string) Option {
+	return func(c *Codec) error {
+		d := &dictionary{phrases: make(map[string]string, len(dict))}
+		codes := make(map[string]string, len(dict)) // NFC phrase -> code
+		phrases := make([]string, 0, len(dict))
+		for phrase, code := range dict {
+			phrase = toNFC(phrase)
+			switch {
+			case phrase == "" || code == "":
+				return errors.New("dictionary phrases and codes must not be empty")
+			case strings.Contains(phrase+code, dictMark):
+				return fmt.Errorf("dictionary entry %q contains the reserved character U+001A", phrase)
+			}
+			if other, dup := d.phrases[code]; dup {
+				return fmt.Errorf("dictionary code %q is used for both %q and %q", code, other, phrase)
+			}
+			if _, dup := codes[phrase]; dup {
+				return fmt.Errorf("dictionary phrase %q appears twice after NFC normalization", phrase)
+			}
+			d.phrases[code] = phrase
+			codes[phrase] = code
+			phrases = append(phrases, phrase)
+		}
+
+		// Longer phrases first, so "good morning" wins over "good".
+		sort.Slice(phrases, func(i, j int) bool {
+			if len(phrases[i]) != len(phrases[j]) {
+				return len(phrases[i]) > len(phrases[j])
+			}
+			return phrases[i] < phrases[j]
+		})
+		var pairs []string
+		h := sha256.New()
+		for _, phrase := range phrases {
+			code := codes[phrase]
+			pairs = append(pairs, phrase, dictMark+code+dictMark)
+			fmt.Fprintf(h, "%d:%s%d:%s", len(phrase), phrase, len(code), code)
+		}
+		copy(d.hash[:], h.Sum(nil))
+		d.encode = strings.NewReplacer(pairs...)
+		c.dict = d
+		return nil
+	}
+}
+
+// substitute returns the payload for text: the dictionary hash and then text
+// with every phrase replaced by its marked code.
+func (d *dictionary) substitute(text string) ([]byte, error) {
+	if strings.Contains(text, dictMark) {
+		return nil, errors.New("input contains U+001A, which the dictionary layer reserves")
+	}
+	return append(d.hash[:], d.encode.Replace(text)...), nil
+}
+
+// expand undoes substitute.
+func (d *dictionary) expand(payload []byte) (string, error) {
+	body, ok := bytes.CutPrefix(payload, d.hash[:])
+	if !ok {
+		return "", errors.New("payload was not encoded with this dictionary")
+	}
+	parts := strings.Split(string(body), dictMark)
+	if len(parts)%2 == 0 {
+		return "", errors.New("unterminated dictionary code in payload")
+	}
+	for i := 1; i < len(parts); i += 2 {
+		phrase, ok := d.phrases[parts[i]]
+		if !ok {
+			return "", fmt.Errorf("unknown dictionary code %q", parts[i])
+		}
+		parts[i] = phrase
+	}
+	return strings.Join(parts, ""), nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDictionaryRoundTrip(t *testing.T) {
+	dict := map[string]string{"good morning": "gm", "good": "g", "汪汪隊": "w"}
+	c := mustCodec(t, WithDictionary(dict))
+	plain := mustCodec(t)
+	for _, text := range []string{"", "good morning, good dog", "汪汪隊 good", "no phrases here"} {
+		speech, err := c.Encode(text)
+		if err != nil {
+			t.Fatalf("%q: %v", text, err)
+		}
+		got, err := c.Decode(speech)
+		if err != nil || got != text {
+			t.Fatalf("%q: decoded %q, %v", text, got, err)
+		}
+		if strings.Contains(text, "good morning") {
+			unsubstituted, err := plain.Encode(text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if countTokens(speech) >= countTokens(unsubstituted) {
+				t.Errorf("%q: %d tokens with the dictionary, %d without", text, countTokens(speech), countTokens(unsubstituted))
+			}
+		}
+	}
+}
+
+func TestDictionaryMismatch(t *testing.T) {
+	speech, err := mustCodec(t, WithDictionary(map[string]string{"hello": "h"})).Encode("hello there")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mustCodec(t, WithDictionary(map[string]string{"hello": "x"})).Decode(speech); err == nil {
+		t.Error("a different dictionary decoded the frame")
+	}
+	if _, err := mustCodec(t, WithDictionary(map[string]string{"hello": "h"})).Encode("has \x1a in it"); err == nil {
+		t.Error("encoded text containing the dictionary mark")
+	}
+}
+
+func TestDictionaryRejectsBadEntries(t *testing.T) {
+	for _, dict := range []map[string]string{
+		{"": "e"},
+		{"phrase": ""},
+		{"a": "x", "b": "x"},
+		{"bad\x1a": "b"},
+	} {
+		if _, err := NewCodec(WithDictionary(dict)); err == nil {
+			t.Errorf("WithDictionary accepted %q", dict)
+		}
+	}
+}
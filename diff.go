@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// tokenFields splits dog speech into its whitespace-separated tokens after NFC normalization.
+func tokenFields(dogSpeech string) []string {
+	var fields []string
+	s := toNFC(dogSpeech)
+	for tok, rest := nextToken(s); tok != ""; tok, rest = nextToken(rest) {
+		fields = append(fields, tok)
+	}
+	return fields
+}
+
+// diffTokens compares a and b position by position and describes every token
+// that differs, plus a summary line. Positions are 0-based; for tokens past the
+// header it also names the payload byte the token's first bits land in.
+func diffTokens(a, b string) string {
+	ta, tb := tokenFields(a), tokenFields(b)
+	n := max(len(ta), len(tb))
+
+	var sb strings.Builder
+	differ := 0
+	for i := 0; i < n; i++ {
+		x, y := "<missing>", "<missing>"
+		if i < len(ta) {
+			x = ta[i]
+		}
+		if i < len(tb) {
+			y = tb[i]
+		}
+		if x == y {
+			continue
+		}
+		differ++
+		where := fmt.Sprintf("token %d", i)
+		if pb := i*6/8 - 4; pb >= 0 {
+			where += fmt.Sprintf(" (payload byte %d)", pb)
+		} else {
+			where += " (header)"
+		}
+		fmt.Fprintf(&sb, "%s: %s | %s\n", where, x, y)
+	}
+	fmt.Fprintf(&sb, "%d of %d token positions differ", differ, n)
+	return sb.String()
+}
+
+func newDiffCmd(outFile *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff a.woof b.woof",
+		Short: "Show which token positions differ between two dog-speech files",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := readFile(args[0], nil)
+			if err != nil {
+				return fmt.Errorf("read input error: %w", err)
+			}
+			b, err := readFile(args[1], nil)
+			if err != nil {
+				return fmt.Errorf("read input error: %w", err)
+			}
+			if err := writeResult(cmd.OutOrStdout(), *outFile, diffTokens(a, b)); err != nil {
+				return fmt.Errorf("write output error: %w", err)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDiffCommand(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	fields := strings.Fields(mustEncode(t, "abc"))
+	changed := strings.Fields(bump(t, fields, 2))
+	changed = strings.Fields(bump(t, changed, 8))
+	a := write("a.woof", strings.Join(fields, " "))
+	b := write("b.woof", strings.Join(changed[:len(changed)-1], "\n"))
+
+	out, _, err := runCLI(t, "", "diff", a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := len(fields) - 1
+	want := "token 2 (header): " + fields[2] + " | " + changed[2] + "\n" +
+		"token 8 (payload byte 2): " + fields[8] + " | " + changed[8] + "\n" +
+		"token " + strconv.Itoa(last) + " (payload byte " + strconv.Itoa(last*6/8-4) + "): " + fields[last] + " | <missing>\n" +
+		"3 of " + strconv.Itoa(len(fields)) + " token positions differ\n"
+	if out != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+
+	if out, _, err := runCLI(t, "", "diff", a, a); err != nil || out != "0 of "+strconv.Itoa(len(fields))+" token positions differ\n" {
+		t.Errorf("diff of a file with itself: %q, %v", out, err)
+	}
+	if _, _, err := runCLI(t, "", "diff", a); err == nil {
+		t.Error("diff with one file: no error")
+	}
+}
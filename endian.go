@@ -0,0 +1,37 @@
+package main
+
+import "encoding/binary"
+
+// EncodeBytesLE is EncodeBytes with the length header written little-endian.
+// The real format is big-endian; this only exists to reproduce what a decoder
+// with the byte order wrong expects, e.g. from a bug report.
+func EncodeBytesLE(payload []byte) string {
+	total := make([]byte, 4+len(payload))
+	binary.LittleEndian.PutUint32(total, uint32(len(payload)))
+	copy(total[4:], payload)
+	return encodeFrame(total)
+}
+
+// DecodeBytesLE is DecodeBytes for a stream whose length header is
+// little-endian, as written by EncodeBytesLE or by an encoder with the byte
+// order wrong. A big-endian stream decoded this way (or the other way round)
+// almost always fails the length check, since the swapped header declares far
+// more bytes than there are.
+func DecodeBytesLE(dogSpeech string) ([]byte, error) {
+	ids, marked, err := padTrimmedIDs(dogSpeech)
+	if err != nil {
+		return nil, err
+	}
+	frame := packIDs(ids)
+	if len(frame) >= 4 {
+		frame[0], frame[1], frame[2], frame[3] = frame[3], frame[2], frame[1], frame[0]
+	}
+	payload, err := unframe(frame, len(ids))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPadMark(marked, len(ids), len(payload)); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
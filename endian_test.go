@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLittleEndianRoundTrip(t *testing.T) {
+	for _, payload := range [][]byte{{}, []byte("a"), []byte("little-endian header"), bytes.Repeat([]byte{0xff}, 300)} {
+		speech := EncodeBytesLE(payload)
+		for _, in := range []string{speech, speech + " " + PadToken} {
+			got, err := DecodeBytesLE(in)
+			if err != nil {
+				t.Fatalf("%q: %v", in, err)
+			}
+			if !bytes.Equal(got, payload) {
+				t.Fatalf("%q: got %q, want %q", in, got, payload)
+			}
+		}
+	}
+}
+
+func TestLittleEndianRejectsBigEndian(t *testing.T) {
+	speech := EncodeBytes([]byte("big-endian frame"))
+	if _, err := DecodeBytesLE(speech); err == nil {
+		t.Error("DecodeBytesLE accepted a big-endian frame")
+	}
+	if _, err := DecodeBytes(EncodeBytesLE([]byte("little-endian frame"))); err == nil {
+		t.Error("DecodeBytes accepted a little-endian frame")
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// EscapeTokens writes every non-ASCII rune of dogSpeech as a JSON-style \uXXXX
+// escape (a surrogate pair above U+FFFF), so the output survives ASCII-only
+// channels. ASCII, including the separators, is kept as is.
+func EscapeTokens(dogSpeech string) string {
+	var sb strings.Builder
+	sb.Grow(len(dogSpeech) * 2)
+	for _, r := range dogSpeech {
+		if r < utf8.RuneSelf {
+			sb.WriteRune(r)
+			continue
+		}
+		if r > 0xFFFF {
+			hi, lo := utf16.EncodeRune(r)
+			fmt.Fprintf(&sb, `\u%04x\u%04x`, hi, lo)
+			continue
+		}
+		fmt.Fprintf(&sb, `\u%04x`, r)
+	}
+	return sb.String()
+}
+
+// UnescapeTokens undoes EscapeTokens. Input without a \u escape is returned
+// unchanged, so it can run on every decode input.
+func UnescapeTokens(s string) (string, error) {
+	if !strings.Contains(s, `\u`) {
+		return s, nil
+	}
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for {
+		i := strings.Index(s, `\u`)
+		if i < 0 {
+			sb.WriteString(s)
+			return sb.String(), nil
+		}
+		sb.WriteString(s[:i])
+		r, n, err := unescapeRune(s[i:])
+		if err != nil {
+			return "", err
+		}
+		sb.WriteRune(r)
+		s = s[i+n:]
+	}
+}
+
+// unescapeRune reads the \uXXXX escape at the start of s, joining a surrogate
+// pair into one rune, and returns how many bytes it took.
+func unescapeRune(s string) (rune, int, error) {
+	hex4 := func(s string) (rune, bool) {
+		if len(s) < 6 || s[:2] != `\u` {
+			return 0, false
+		}
+		v, err := strconv.ParseUint(s[2:6], 16, 16)
+		return rune(v), err == nil
+	}
+	r, ok := hex4(s)
+	if !ok {
+		return 0, 0, fmt.Errorf("malformed escape %q (want \\uXXXX)", s[:min(len(s), 6)])
+	}
+	if !utf16.IsSurrogate(r) {
+		return r, 6, nil
+	}
+	lo, ok := hex4(s[6:])
+	if pair := utf16.DecodeRune(r, lo); ok && pair != utf8.RuneError {
+		return pair, 12, nil
+	}
+	return 0, 0, fmt.Errorf("unpaired surrogate in escape %q", s[:6])
+}
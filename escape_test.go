@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestEncodeEscape(t *testing.T) {
+	speech := mustEncode(t, "abc")
+	out, _, err := runCLI(t, "", "encode", "--escape", "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(out); i++ {
+		if out[i] >= utf8.RuneSelf {
+			t.Fatalf("--escape wrote non-ASCII: %q", out)
+		}
+	}
+	if !strings.HasPrefix(out, `\u6c6a \u6c6a `) || out != EscapeTokens(speech)+"\n" {
+		t.Errorf("--escape: %q", out)
+	}
+	if got, _, err := runCLI(t, out, "decode"); err != nil || got != "abc\n" {
+		t.Errorf("decode of --escape output: %q, %v", got, err)
+	}
+}
+
+func TestUnescapeTokens(t *testing.T) {
+	for in, want := range map[string]string{
+		`\u6c6a~ \u55da`:   "汪~ 嗚",
+		`\ud83d\udc36`:     "🐶",
+		`\u6C6A`:           "汪",
+		"plain 汪":          "plain 汪",
+		EscapeTokens("🐶汪"): "🐶汪",
+	} {
+		if got, err := UnescapeTokens(in); err != nil || got != want {
+			t.Errorf("UnescapeTokens(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{`\u6c6`, `\uzzzz`, `\ud83d`, `\ud83dA`} {
+		if _, err := UnescapeTokens(bad); err == nil {
+			t.Errorf("UnescapeTokens(%q): no error", bad)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// examples are the inputs woofwoof examples shows, one per kind of text.
+var examples = []struct{ name, text string }{
+	{"hello world", "hello world"},
+	{"CJK", "我是小狗"},
+	{"emoji", "🐶❤️"},
+	{"empty", ""},
+}
+
+// examplesText encodes every example live, so the pairs never drift from the
+// implementation, and checks that each decodes back to its input.
+func examplesText() (string, error) {
+	var sb strings.Builder
+	for i, ex := range examples {
+		out, err := Encode(ex.text)
+		if err != nil {
+			return "", fmt.Errorf("example %q: %w", ex.name, err)
+		}
+		if back, err := Decode(out); err != nil || back != ex.text {
+			return "", fmt.Errorf("example %q does not round-trip: %q, %v", ex.name, back, err)
+		}
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		fmt.Fprintf(&sb, "# %s\n%q\n%s", ex.name, ex.text, out)
+	}
+	return sb.String(), nil
+}
+
+func newExamplesCmd(outFile *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "examples",
+		Short: "Print example inputs with their dog speech, for docs and screenshots",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := examplesText()
+			if err != nil {
+				return err
+			}
+			if err := writeResult(cmd.OutOrStdout(), *outFile, out); err != nil {
+				return fmt.Errorf("write output error: %w", err)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestExamplesCommand(t *testing.T) {
+	out, _, err := runCLI(t, "", "examples")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks := strings.Split(strings.TrimSuffix(out, "\n"), "\n\n")
+	if len(blocks) != len(examples) {
+		t.Fatalf("%d examples printed, want %d:\n%s", len(blocks), len(examples), out)
+	}
+	for i, ex := range examples {
+		want := "# " + ex.name + "\n" + strconv.Quote(ex.text) + "\n" + mustEncode(t, ex.text)
+		if blocks[i] != want {
+			t.Errorf("example %d: got\n%s\nwant\n%s", i, blocks[i], want)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "examples.txt")
+	if _, _, err := runCLI(t, "", "examples", "-o", path); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(path); err != nil || string(b) != out {
+		t.Errorf("examples -o: %q, %v", b, err)
+	}
+	if _, _, err := runCLI(t, "", "examples", "extra"); err == nil {
+		t.Error("examples with an argument: no error")
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// idMapEntry is one codebook entry as emitted by emit-id-map.
+type idMapEntry struct {
+	ID    int    `json:"id"`
+	Token string `json:"token"`
+}
+
+// idMapJSON renders the codebook as JSON with both lookup directions.
+func idMapJSON() (string, error) {
+	m := struct {
+		IDToToken []idMapEntry   `json:"id_to_token"`
+		TokenToID map[string]int `json:"token_to_id"`
+	}{TokenToID: make(map[string]int, len(codebook))}
+	for id, tok := range codebook {
+		m.IDToToken = append(m.IDToToken, idMapEntry{ID: id, Token: tok})
+		m.TokenToID[tok] = id
+	}
+	var sb strings.Builder
+	enc := json.NewEncoder(&sb)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
+
+// idMapCSV renders the codebook as "id,token" rows; the table reads both ways.
+func idMapCSV() (string, error) {
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	w.Write([]string{"id", "token"})
+	for id, tok := range codebook {
+		w.Write([]string{strconv.Itoa(id), tok})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
+
+func newEmitIDMapCmd(outFile *string) *cobra.Command {
+	var format string
+	cmd := &cobra.Command{
+		Use:   "emit-id-map",
+		Short: "Print the id<->token codebook as JSON or CSV for reimplementers",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var out string
+			var err error
+			switch strings.ToLower(format) {
+			case "json":
+				out, err = idMapJSON()
+			case "csv":
+				out, err = idMapCSV()
+			default:
+				return fmt.Errorf("unknown format %q (want json or csv)", format)
+			}
+			if err != nil {
+				return err
+			}
+			if err := writeResult(cmd.OutOrStdout(), *outFile, out); err != nil {
+				return fmt.Errorf("write output error: %w", err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "json", "output format: json or csv")
+	return cmd
+}
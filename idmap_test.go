@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestIDMapJSON(t *testing.T) {
+	out, err := idMapJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m struct {
+		IDToToken []idMapEntry   `json:"id_to_token"`
+		TokenToID map[string]int `json:"token_to_id"`
+	}
+	if err := json.Unmarshal([]byte(out), &m); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.IDToToken) != 64 || len(m.TokenToID) != 64 {
+		t.Fatalf("%d ids, %d tokens; want 64 each", len(m.IDToToken), len(m.TokenToID))
+	}
+	for i, e := range m.IDToToken {
+		if e.ID != i || e.Token != codebook[i] || m.TokenToID[e.Token] != i {
+			t.Fatalf("entry %d: %+v, token_to_id %d", i, e, m.TokenToID[e.Token])
+		}
+	}
+}
+
+func TestIDMapCSV(t *testing.T) {
+	out, err := idMapCSV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 65 || rows[0][0] != "id" || rows[0][1] != "token" {
+		t.Fatalf("%d rows, header %q", len(rows), rows[0])
+	}
+	for i, row := range rows[1:] {
+		if id, err := strconv.Atoi(row[0]); err != nil || id != i || row[1] != codebook[i] {
+			t.Fatalf("row %d: %q", i, row)
+		}
+		if got, ok := lookupToken(row[1]); !ok || int(got) != i {
+			t.Fatalf("row %d: token %q decodes to %d", i, row[1], got)
+		}
+	}
+}
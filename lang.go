@@ -0,0 +1,14 @@
+//go:build !woof_nonorm
+
+package main
+
+import "golang.org/x/text/language"
+
+// canonicalTag parses a BCP 47 language tag and returns its canonical form.
+func canonicalTag(tag string) (string, error) {
+	t, err := language.Parse(tag)
+	if err != nil {
+		return "", err
+	}
+	return t.String(), nil
+}
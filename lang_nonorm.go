@@ -0,0 +1,20 @@
+//go:build woof_nonorm
+
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+// canonicalTag only checks that tag is well-formed on the surface (ASCII
+// letters and digits in subtags of 1 to 8, joined by hyphens) and returns it
+// as given, since this build has no x/text/language to canonicalize it.
+func canonicalTag(tag string) (string, error) {
+	for _, sub := range strings.Split(tag, "-") {
+		if len(sub) < 1 || len(sub) > 8 || strings.Trim(sub, "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+			return "", errors.New("tag is not well-formed")
+		}
+	}
+	return tag, nil
+}
@@ -0,0 +1,13 @@
+//go:build !woof_nonorm
+
+package main
+
+import "testing"
+
+func TestCanonicalTag(t *testing.T) {
+	for in, want := range map[string]string{"ja": "ja", "zh-hant": "zh-Hant", "EN-us": "en-US"} {
+		if got, err := canonicalTag(in); err != nil || got != want {
+			t.Errorf("canonicalTag(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+// The decode hot path looks tokens up in a collision-free 256-slot table
+// instead of reverseTable. The hash seed is searched for once at startup, so the
+// table works for whatever 64 tokens the codebook holds. Compared with the map,
+// a lookup is one short FNV-style hash plus a single string compare;
+// BenchmarkTokenLookup measures the two side by side.
+
+var (
+	tokenSeed  uint32
+	tokenSlots [256]int16 // codebook index, or -1 for an empty slot
+)
+
+// tokenHash hashes s into a slot of tokenSlots.
+func tokenHash(s string, seed uint32) uint32 {
+	h := seed
+	for i := 0; i < len(s); i++ {
+		h = (h ^ uint32(s[i])) * 16777619
+	}
+	return (h ^ h>>15) & 255
+}
+
+// buildTokenIndex finds a seed for which every codebook token gets its own slot.
+func buildTokenIndex() {
+	for seed := uint32(2166136261); seed != 2166136261+1<<20; seed++ {
+		var slots [256]int16
+		for i := range slots {
+			slots[i] = -1
+		}
+		ok := true
+		for i, tok := range codebook {
+			h := tokenHash(tok, seed)
+			if slots[h] >= 0 {
+				ok = false
+				break
+			}
+			slots[h] = int16(i)
+		}
+		if ok {
+			tokenSeed, tokenSlots = seed, slots
+			return
+		}
+	}
+	panic("no collision-free token hash seed found")
+}
+
+// lookupToken returns the 6-bit id of tok; it agrees with reverseTable.
+func lookupToken(tok string) (byte, bool) {
+	i := tokenSlots[tokenHash(tok, tokenSeed)]
+	if i < 0 || codebook[i] != tok {
+		return 0, false
+	}
+	return byte(i), true
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookupTokenAgreesWithMap(t *testing.T) {
+	for i, tok := range codebook {
+		id, ok := lookupToken(tok)
+		if !ok || int(id) != i || reverseTable[tok] != id {
+			t.Errorf("%q: lookupToken = %d, %v; want %d", tok, id, ok, i)
+		}
+	}
+	for _, s := range []string{"", "woof", PadToken, codebook[0] + codebook[1], codebook[63] + "!", strings.Repeat("x", 100)} {
+		id, ok := lookupToken(s)
+		if want, inMap := reverseTable[s]; ok != inMap || id != want {
+			t.Errorf("lookupToken(%q) = %d, %v; reverseTable has %d, %v", s, id, ok, want, inMap)
+		}
+	}
+}
+
+// benchTokens is a mix of CJK and ASCII text as tokens, the order Decode sees them.
+func benchTokens(b *testing.B) []string {
+	b.Helper()
+	speech, err := Encode(strings.Repeat("汪汪 woof! 你好，世界 ", 64))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return strings.Fields(speech)
+}
+
+func BenchmarkTokenLookup(b *testing.B) {
+	toks := benchTokens(b)
+	b.Run("map", func(b *testing.B) {
+		b.ReportAllocs()
+		var sum int
+		for b.Loop() {
+			for _, tok := range toks {
+				id, ok := reverseTable[tok]
+				if ok {
+					sum += int(id)
+				}
+			}
+		}
+		_ = sum
+	})
+	b.Run("perfect-hash", func(b *testing.B) {
+		b.ReportAllocs()
+		var sum int
+		for b.Loop() {
+			for _, tok := range toks {
+				id, ok := lookupToken(tok)
+				if ok {
+					sum += int(id)
+				}
+			}
+		}
+		_ = sum
+	})
+}
@@ -2,15 +2,26 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 	"unicode/utf8"
 
 	"github.com/spf13/cobra"
-	"golang.org/x/text/unicode/norm"
 )
 
 var (
@@ -36,10 +47,35 @@ var (
 		"~.", // 7 (two-char tone, still no spaces)
 	}
 
+	// codebook, reverseTable and the token index are filled once in init and only read afterwards,
+	// so the Encode* and Decode* functions are safe for concurrent use.
 	codebook     []string
 	reverseTable map[string]byte
+
+	// utf8BOM is U+FEFF as written by Windows editors at the start of a file.
+	utf8BOM = "\uFEFF"
+
+	errInvalidInput   = errors.New("input is not valid UTF-8")
+	errInvalidPayload = errors.New("decoded payload is not valid UTF-8 (token stream may be corrupted)")
+
+	// ErrTooManyTokens is returned by DecodeWithLimit when the input has more tokens than allowed.
+	ErrTooManyTokens = errors.New("too many tokens")
 )
 
+// FormatVersion names the frame layout Encode writes: a 4-byte big-endian
+// payload length, then header and payload packed 6 bits per token from the fixed
+// 64-token codebook. It is not written into the output, so a change to the
+// layout must stay decodable by the current decoder or bump this constant.
+const FormatVersion = 1
+
+// defaultDelimiter is what encode --delimiter puts between tokens when given no
+// value: ASCII, left alone by chats that collapse or trim spaces, and in no token.
+const defaultDelimiter = "|"
+
+// PadToken can be appended after the last, zero-padded token to make the end
+// of a message visible. It is not in the codebook, so it can't be mistaken for data.
+const PadToken = "嗷嗚"
+
 func init() {
 	codebook = make([]string, 0, 64)
 	reverseTable = make(map[string]byte, 64)
@@ -59,32 +95,99 @@ func init() {
 	if len(codebook) != 64 {
 		panic("codebook size is not 64")
 	}
+	if _, exists := reverseTable[PadToken]; exists {
+		panic("pad token is in the codebook: " + PadToken)
+	}
+	if err := checkCodebook(codebook); err != nil {
+		panic(err)
+	}
+	buildTokenIndex()
+}
+
+// checkCodebook rejects tokens the wire format could not carry.
+func checkCodebook(tokens []string) error {
+	for _, token := range tokens {
+		if strings.Contains(token, runMark) {
+			return fmt.Errorf("codebook token contains the run mark: %q", token)
+		}
+		// Tokens are whitespace-separated, so one containing a space could never be decoded.
+		if strings.IndexFunc(token, unicode.IsSpace) >= 0 {
+			return fmt.Errorf("codebook token contains whitespace: %q", token)
+		}
+	}
+	return nil
 }
 
 // Encode turns arbitrary UTF-8 text into dog-speech tokens.
 func Encode(input string) (string, error) {
 	// Normalize to NFC so visually-similar Unicode sequences become consistent.
-	input = norm.NFC.String(input)
+	input = toNFC(input)
 
 	// In Go, strings can contain invalid UTF-8; decide policy: reject invalid.
 	if !utf8.ValidString(input) {
-		return "", errors.New("input is not valid UTF-8")
+		return "", errInvalidInput
 	}
 
-	payload := []byte(input)
+	return EncodeBytes([]byte(input)), nil
+}
+
+// EncodeBytes turns arbitrary bytes into dog-speech tokens. Unlike Encode it
+// neither normalizes nor validates the payload; use DecodeBytes to get it back.
+func EncodeBytes(payload []byte) string {
+	scratch := getScratch(4 + len(payload))
+	defer putScratch(scratch)
 
 	// Header: 4-byte length (big-endian)
-	total := make([]byte, 4+len(payload))
+	total := *scratch
 	binary.BigEndian.PutUint32(total[:4], uint32(len(payload)))
 	copy(total[4:], payload)
 
-	// Convert bytes to 6-bit tokens
-	var outTokens []string
+	return encodeFrame(total)
+}
+
+// EncodePadded is like Encode, but zero-fills the payload up to size bytes so
+// every input of at most size bytes yields the same number of tokens. The
+// header keeps the real length, so Decode returns the original unchanged.
+func EncodePadded(input string, size int) (string, error) {
+	input = toNFC(input)
+	if !utf8.ValidString(input) {
+		return "", errInvalidInput
+	}
+	return EncodeBytesPadded([]byte(input), size)
+}
+
+// EncodeBytesPadded is the byte-level counterpart of EncodePadded.
+func EncodeBytesPadded(payload []byte, size int) (string, error) {
+	if size < 0 {
+		return "", fmt.Errorf("padded size must not be negative, got %d", size)
+	}
+	if len(payload) > size {
+		return "", fmt.Errorf("input is %d bytes, larger than the padded size %d", len(payload), size)
+	}
+	scratch := getScratch(4 + size)
+	defer putScratch(scratch)
+
+	total := *scratch
+	binary.BigEndian.PutUint32(total[:4], uint32(len(payload)))
+	copy(total[4:], payload)
+	clear(total[4+len(payload):])
+
+	return encodeFrame(total), nil
+}
+
+// encodeFrame turns a framed buffer (header included) into space-separated tokens.
+func encodeFrame(total []byte) string {
+	// Write tokens straight into the result; roughly 8 bytes per token with its separator.
+	var sb strings.Builder
+	sb.Grow(((len(total)*8 + 5) / 6) * 8)
 	var bitBuf uint32
 	var bitCount uint8
 
 	emit6 := func(v byte) {
-		outTokens = append(outTokens, codebook[v&0x3F])
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(codebook[v&0x3F])
 	}
 
 	for _, b := range total {
@@ -110,29 +213,382 @@ func Encode(input string) (string, error) {
 		emit6(chunk)
 	}
 
-	return strings.Join(outTokens, " "), nil
+	return sb.String()
 }
 
 // Decode turns dog-speech tokens back into the original UTF-8 text.
 func Decode(dogSpeech string) (string, error) {
-	// Normalize NFC to reduce Unicode representation issues (esp. if copy/pasted).
-	dogSpeech = norm.NFC.String(strings.TrimSpace(dogSpeech))
+	payload, err := DecodeBytes(dogSpeech)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(payload) {
+		return "", errInvalidPayload
+	}
+	return string(payload), nil
+}
+
+// DecodeWithLimit is like Decode but fails with ErrTooManyTokens, before any
+// token is looked up, when dogSpeech has more than maxTokens fields. It bounds
+// the work on untrusted input whatever length the header claims.
+func DecodeWithLimit(dogSpeech string, maxTokens int) (string, error) {
+	if maxTokens < 0 {
+		return "", fmt.Errorf("maxTokens must not be negative, got %d", maxTokens)
+	}
+	n := 0
+	body, _ := trimPadToken(dogSpeech)
+	for tok, rest := nextToken(body); tok != ""; tok, rest = nextToken(rest) {
+		if n++; n > maxTokens {
+			return "", fmt.Errorf("%w: more than %d", ErrTooManyTokens, maxTokens)
+		}
+	}
+	return Decode(dogSpeech)
+}
+
+// DecodeBytes turns dog-speech tokens back into the original bytes without
+// requiring them to be valid UTF-8.
+func DecodeBytes(dogSpeech string) ([]byte, error) {
+	dogSpeech, marked := trimPadToken(dogSpeech)
+	payload, tokens, err := decodeTokens(dogSpeech)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPadMark(marked, tokens, len(payload)); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// decodeTokens is appendIDs, packIDs and unframe in a single pass: each id goes
+// straight into the bit accumulator, so no ids slice is built. Once the header
+// is in, the frame is allocated at its declared size, capped by what the input
+// could possibly carry; bytes past the frame are checked as tokens but dropped.
+// It also returns the token count.
+func decodeTokens(dogSpeech string) ([]byte, int, error) {
+	dogSpeech = toNFC(strings.TrimSpace(dogSpeech))
+	if dogSpeech == "" {
+		return nil, 0, errors.New("empty input")
+	}
+
+	var header [4]byte
+	var bytesOut []byte
+	var bitBuf uint32
+	var bitCount uint8
+	have, need, tokens := 0, -1, 0
+	for tok, rest := nextToken(dogSpeech); tok != ""; tok, rest = nextToken(rest) {
+		id, ok := lookupToken(tok)
+		if !ok {
+			if tokens == 0 {
+				return nil, 0, unknownFirstTokenError(dogSpeech)
+			}
+			return nil, 0, fmt.Errorf("unknown token: %q", tok)
+		}
+		tokens++
+
+		bitBuf = (bitBuf << 6) | uint32(id&0x3F)
+		bitCount += 6
+		if bitCount < 8 {
+			continue
+		}
+		bitCount -= 8
+		b := byte(bitBuf >> bitCount)
+		bitBuf &= (1 << bitCount) - 1
+
+		switch {
+		case need < 0:
+			header[have] = b
+			if have++; have == 4 {
+				need = int(binary.BigEndian.Uint32(header[:]))
+				// Every token is at least one rune and a separator, i.e. 4 bytes of input.
+				bytesOut = make([]byte, 0, min(need, (len(dogSpeech)+1)/4*6/8))
+			}
+		case len(bytesOut) < need:
+			bytesOut = append(bytesOut, b)
+		}
+	}
+
+	switch {
+	case need < 0:
+		return nil, 0, shortError(tokens)
+	case len(bytesOut) < need:
+		return nil, 0, incompleteError(uint64(need), len(bytesOut), tokens)
+	}
+	return bytesOut, tokens, nil
+}
+
+// trimPadToken strips a trailing PadToken and reports whether there was one.
+func trimPadToken(dogSpeech string) (string, bool) {
+	trimmed := strings.TrimRightFunc(dogSpeech, unicode.IsSpace)
+	rest, ok := strings.CutSuffix(trimmed, PadToken)
+	if !ok || (rest != "" && !unicode.IsSpace(lastRune(rest))) {
+		return dogSpeech, false
+	}
+	return rest, true
+}
+
+// checkPadMark reports a pad token that does not directly follow the last
+// token of a frame carrying n payload bytes.
+func checkPadMark(marked bool, tokens, n int) error {
+	if marked && tokens != tokensFor(n) {
+		return fmt.Errorf("pad token after %d tokens, but the header declares a %d-token frame", tokens, tokensFor(n))
+	}
+	return nil
+}
+
+// padTrimmedIDs is DecodeToIDs for the decoders, which all accept input ending
+// in PadToken: the marker is dropped first and reported through marked.
+func padTrimmedIDs(dogSpeech string) (ids []byte, marked bool, err error) {
+	dogSpeech, marked = trimPadToken(dogSpeech)
+	ids, err = DecodeToIDs(dogSpeech)
+	return ids, marked, err
+}
+
+// lastRune returns the final rune of a non-empty string.
+func lastRune(s string) rune {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r
+}
+
+// Result is what DecodeDetailed reports alongside the decoded text.
+type Result struct {
+	Text         string
+	TokenCount   int
+	PayloadBytes int
+	Warnings     []string
+	Metadata     map[string]string // only from a Codec with WithMetadata
+	Language     string            // the LanguageKey entry of Metadata, if any
+	Time         time.Time         // the TimeKey entry of Metadata (WithTimestamp), if any
+	Label        string            // the LabelKey entry of Metadata, if any
+}
+
+// DecodeDetailed is like Decode but also reports token and payload counts, plus
+// warnings about anything that was tolerated rather than rejected.
+func DecodeDetailed(dogSpeech string) (Result, error) {
+	ids, marked, err := padTrimmedIDs(dogSpeech)
+	if err != nil {
+		return Result{}, err
+	}
+	payload, err := unpackIDs(ids)
+	if err != nil {
+		return Result{}, err
+	}
+	if err := checkPadMark(marked, len(ids), len(payload)); err != nil {
+		return Result{}, err
+	}
+	if !utf8.Valid(payload) {
+		return Result{}, errInvalidPayload
+	}
+
+	res := Result{
+		Text:         string(payload),
+		TokenCount:   len(ids),
+		PayloadBytes: len(payload),
+	}
+	if !isNFC(strings.TrimSpace(dogSpeech)) {
+		res.Warnings = append(res.Warnings, "confusable tokens normalized (input was not NFC)")
+	}
+	switch extra := len(ids) - tokensFor(len(payload)); {
+	case extra == 1:
+		res.Warnings = append(res.Warnings, "1 trailing token ignored")
+	case extra > 1:
+		res.Warnings = append(res.Warnings, fmt.Sprintf("%d trailing tokens ignored", extra))
+	}
+	return res, nil
+}
+
+// DecodePartial is like Decode, but a frame cut short is not an error: it returns
+// the payload bytes that did arrive, up to the last complete rune, with truncated
+// set. Invalid UTF-8 before the cut is still an error.
+func DecodePartial(dogSpeech string) (text string, truncated bool, err error) {
+	ids, marked, err := padTrimmedIDs(dogSpeech)
+	if err != nil {
+		return "", false, err
+	}
+	bytesOut := packIDs(ids)
+	if len(bytesOut) < 4 {
+		return "", false, shortError(len(ids))
+	}
+	if n := binary.BigEndian.Uint32(bytesOut[:4]); uint64(len(bytesOut)-4) >= uint64(n) {
+		if err := checkPadMark(marked, len(ids), int(n)); err != nil {
+			return "", false, err
+		}
+		payload := bytesOut[4 : 4+int(n)]
+		if !utf8.Valid(payload) {
+			return "", false, errInvalidPayload
+		}
+		return string(payload), false, nil
+	}
+
+	payload := bytesOut[4:]
+	// Hold back a rune whose bytes are still on the way.
+	for cut := len(payload) - 1; cut >= 0 && cut >= len(payload)-utf8.UTFMax+1; cut-- {
+		if utf8.RuneStart(payload[cut]) {
+			if !utf8.FullRune(payload[cut:]) {
+				payload = payload[:cut]
+			}
+			break
+		}
+	}
+	if !utf8.Valid(payload) {
+		return "", true, errInvalidPayload
+	}
+	return string(payload), true, nil
+}
+
+// FrameInfo describes a frame as InspectFrame sees it from its header.
+type FrameInfo struct {
+	PayloadLength int  // payload bytes the length header declares
+	FrameTokens   int  // tokens a complete frame of that length takes
+	Tokens        int  // tokens present in the input
+	Complete      bool // Tokens >= FrameTokens
+}
+
+// InspectFrame reads only the length header (the first six tokens) and counts
+// the remaining tokens without looking them up or unpacking the payload.
+func InspectFrame(dogSpeech string) (FrameInfo, error) {
+	dogSpeech, _ = trimPadToken(toNFC(strings.TrimSpace(dogSpeech)))
 	if dogSpeech == "" {
+		return FrameInfo{}, errors.New("empty input")
+	}
+
+	var head uint64
+	rest := dogSpeech
+	for i := 0; i < minHeaderTokens; i++ {
+		var tok string
+		tok, rest = nextToken(rest)
+		if tok == "" {
+			return FrameInfo{}, shortError(i)
+		}
+		id, ok := lookupToken(tok)
+		if !ok {
+			return FrameInfo{}, fmt.Errorf("unknown token: %q", tok)
+		}
+		head = head<<6 | uint64(id)
+	}
+
+	info := FrameInfo{
+		PayloadLength: int(head >> 4),
+		Tokens:        minHeaderTokens + countTokens(rest),
+	}
+	info.FrameTokens = tokensFor(info.PayloadLength)
+	info.Complete = info.Tokens >= info.FrameTokens
+	return info, nil
+}
+
+// DecodeRange returns bytes [start, end) of the payload as text, looking up only
+// the header tokens and the tokens that carry those bytes; tokens before them
+// are skipped without lookup and tokens after them are not read at all. The
+// range must not split a UTF-8 sequence.
+func DecodeRange(dogSpeech string, start, end int) (string, error) {
+	rest := toNFC(strings.TrimSpace(dogSpeech))
+	if rest == "" {
 		return "", errors.New("empty input")
 	}
 
-	parts := strings.Fields(dogSpeech) // splits on any whitespace; output format is still "space-separated"
-	ids := make([]byte, 0, len(parts))
-	for _, tok := range parts {
-		id, ok := reverseTable[tok]
+	lookup := func(tok string) (byte, error) {
+		id, ok := lookupToken(tok)
 		if !ok {
-			return "", fmt.Errorf("unknown token: %q", tok)
+			return 0, fmt.Errorf("unknown token: %q", tok)
+		}
+		return id, nil
+	}
+	var ids []byte
+	var head uint64
+	for i := 0; i < minHeaderTokens; i++ {
+		var tok string
+		if tok, rest = nextToken(rest); tok == "" {
+			return "", shortError(i)
+		}
+		id, err := lookup(tok)
+		if err != nil {
+			return "", err
 		}
 		ids = append(ids, id)
+		head = head<<6 | uint64(id)
+	}
+	n := int(head >> 4)
+	if start < 0 || end < start || end > n {
+		return "", fmt.Errorf("range [%d:%d] out of bounds for a %d-byte payload", start, end, n)
+	}
+	if start == end {
+		return "", nil
 	}
 
-	// Convert 6-bit ids to bytes
-	var bytesOut []byte
+	// Read one byte past the range to tell whether end splits a rune.
+	stop := min(end+1, n)
+	t0 := (4 + start) * 8 / 6
+	t1 := ((4+stop)*8 + 5) / 6
+	if t0 < minHeaderTokens {
+		ids = ids[t0:]
+	} else {
+		ids = ids[:0]
+	}
+	for i := minHeaderTokens; i < t1; i++ {
+		var tok string
+		if tok, rest = nextToken(rest); tok == "" {
+			return "", incompleteError(uint64(n), i*6/8-4, i)
+		}
+		if i < t0 {
+			continue
+		}
+		id, err := lookup(tok)
+		if err != nil {
+			return "", err
+		}
+		ids = append(ids, id)
+	}
+
+	// Shift out the bits before start, then collect whole bytes.
+	skip := uint8((4+start)*8 - t0*6)
+	out := make([]byte, 0, stop-start)
+	var bitBuf uint32
+	var bitCount uint8
+	for _, id := range ids {
+		bitBuf = bitBuf<<6 | uint32(id)
+		bitCount += 6
+		if skip > 0 {
+			bitCount -= skip
+			bitBuf &= 1<<bitCount - 1
+			skip = 0
+		}
+		for bitCount >= 8 && len(out) < stop-start {
+			bitCount -= 8
+			out = append(out, byte(bitBuf>>bitCount))
+			bitBuf &= 1<<bitCount - 1
+		}
+	}
+
+	if !utf8.RuneStart(out[0]) || (stop > end && !utf8.RuneStart(out[len(out)-1])) {
+		return "", fmt.Errorf("range [%d:%d] splits a UTF-8 sequence", start, end)
+	}
+	out = out[:end-start]
+	if !utf8.Valid(out) {
+		return "", errInvalidPayload
+	}
+	return string(out), nil
+}
+
+// unpackIDs packs 6-bit ids back into bytes and returns the framed payload.
+func unpackIDs(ids []byte) ([]byte, error) {
+	// Reject a header that claims more payload than the tokens can carry before
+	// doing any bit packing. The first 6 tokens (36 bits) hold the 32-bit header.
+	capacity := len(ids) * 6 / 8
+	if len(ids) >= minHeaderTokens {
+		var head uint64
+		for _, id := range ids[:minHeaderTokens] {
+			head = head<<6 | uint64(id&0x3F)
+		}
+		if n := head >> 4; n > uint64(capacity-4) {
+			return nil, incompleteError(n, capacity-4, len(ids))
+		}
+	}
+
+	return unframe(packIDs(ids), len(ids))
+}
+
+// packIDs joins 6-bit ids into bytes, dropping the fewer than 8 bits left at the end.
+func packIDs(ids []byte) []byte {
+	bytesOut := make([]byte, 0, len(ids)*6/8)
 	var bitBuf uint32
 	var bitCount uint8
 
@@ -151,61 +607,669 @@ func Decode(dogSpeech string) (string, error) {
 			}
 		}
 	}
+	return bytesOut
+}
+
+// DecodeHeaderlessBytes decodes a legacy token stream that has no length header:
+// every whole byte the tokens carry is payload. Because an encoder only ever pads
+// to the next 6-bit boundary, the leftover bits never form a byte, so the exact
+// payload comes back. What is lost is every check the header gives: a truncated
+// or extended stream decodes without error, and a stream whose producer padded
+// with extra whole zero tokens yields trailing NUL bytes that cannot be told apart
+// from payload.
+func DecodeHeaderlessBytes(dogSpeech string) ([]byte, error) {
+	ids, _, err := padTrimmedIDs(dogSpeech)
+	if err != nil {
+		return nil, err
+	}
+	return packIDs(ids), nil
+}
+
+// DecodeToIDs maps dog-speech tokens to their 6-bit ids (0-63) without unpacking them.
+func DecodeToIDs(dogSpeech string) ([]byte, error) {
+	ids, err := appendIDs(nil, dogSpeech)
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// appendIDs is DecodeToIDs appending to dst. DecodeBytes does not go through
+// it; decodeTokens does the lookup and the bit packing in one loop instead.
+func appendIDs(dst []byte, dogSpeech string) ([]byte, error) {
+	// Normalize NFC to reduce Unicode representation issues (esp. if copy/pasted).
+	dogSpeech = toNFC(strings.TrimSpace(dogSpeech))
+	if dogSpeech == "" {
+		return dst, errors.New("empty input")
+	}
+
+	// Walk the fields in place; splits on any whitespace like strings.Fields.
+	first, _ := nextToken(dogSpeech)
+	for tok, rest := first, dogSpeech[len(first):]; tok != ""; tok, rest = nextToken(rest) {
+		id, ok := lookupToken(tok)
+		if !ok {
+			if tok == first {
+				return dst, unknownFirstTokenError(dogSpeech)
+			}
+			return dst, fmt.Errorf("unknown token: %q", tok)
+		}
+		dst = append(dst, id)
+	}
+	return dst, nil
+}
+
+// foreignProbeFields is how many leading fields unknownFirstTokenError looks at.
+const foreignProbeFields = 8
+
+// unknownFirstTokenError reports that the first field of dogSpeech is not a
+// token. When none of the first few fields are tokens either, the input is most
+// likely something else entirely (base64, hex, plain text), so it says so.
+func unknownFirstTokenError(dogSpeech string) error {
+	first, rest := nextToken(dogSpeech)
+	var tok string
+	for i := 1; i < foreignProbeFields; i++ {
+		if tok, rest = nextToken(rest); tok == "" {
+			break
+		}
+		if _, ok := lookupToken(tok); ok {
+			return fmt.Errorf("unknown token: %q", first)
+		}
+	}
+	return fmt.Errorf("unknown token: %q: none of the first fields are dog speech; did you mean encode, or is the input in another format?", first)
+}
+
+// EncodeFromIDs renders 6-bit ids as space-separated dog-speech tokens.
+func EncodeFromIDs(ids []byte) (string, error) {
+	tokens := make([]string, len(ids))
+	for i, id := range ids {
+		if int(id) >= len(codebook) {
+			return "", fmt.Errorf("id %d out of range 0-%d", id, len(codebook)-1)
+		}
+		tokens[i] = codebook[id]
+	}
+	return strings.Join(tokens, " "), nil
+}
+
+// formatIDs writes ids as space-separated decimal numbers.
+func formatIDs(ids []byte) string {
+	nums := make([]string, len(ids))
+	for i, id := range ids {
+		nums[i] = strconv.Itoa(int(id))
+	}
+	return strings.Join(nums, " ")
+}
+
+// parseIDs reads whitespace-separated decimal ids as written by formatIDs.
+func parseIDs(s string) ([]byte, error) {
+	s, _ = trimPadToken(s)
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, errors.New("empty input")
+	}
+	ids := make([]byte, len(fields))
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 || n >= len(codebook) {
+			return nil, fmt.Errorf("id %q at position %d is not a number in 0-%d", f, i, len(codebook)-1)
+		}
+		ids[i] = byte(n)
+	}
+	return ids, nil
+}
+
+// DecodeFromIDs unpacks 6-bit ids, as returned by DecodeToIDs, into the payload
+// without going through tokens.
+func DecodeFromIDs(ids []byte) ([]byte, error) {
+	if len(ids) == 0 {
+		return nil, errors.New("empty input")
+	}
+	for i, id := range ids {
+		if int(id) >= len(codebook) {
+			return nil, fmt.Errorf("id %d at position %d out of range 0-%d", id, i, len(codebook)-1)
+		}
+	}
+	return unpackIDs(ids)
+}
+
+// DecodeFirst is like Decode, but it stops reading tokens as soon as the length
+// header is satisfied. Anything after the first frame, valid or not, is ignored,
+// so a small frame at the front of a big buffer decodes without scanning the rest.
+func DecodeFirst(dogSpeech string) (string, error) {
+	payload, err := decodeFirstBytes(dogSpeech)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(payload) {
+		return "", errInvalidPayload
+	}
+	return string(payload), nil
+}
+
+// decodeFirstBytes is the byte-level core of DecodeFirst.
+func decodeFirstBytes(dogSpeech string) ([]byte, error) {
+	dogSpeech, _ = trimPadToken(dogSpeech)
+	rest := strings.TrimSpace(dogSpeech)
+	if rest == "" {
+		return nil, errors.New("empty input")
+	}
+
+	var bytesOut []byte
+	var bitBuf uint32
+	var bitCount uint8
+	need := -1 // header + payload bytes, known once the header is decoded
+	tokens := 0
+
+	for need < 0 || len(bytesOut) < need {
+		var tok string
+		tok, rest = nextToken(rest)
+		if tok == "" {
+			break
+		}
+		// Normalize per token, since the tail of the input is never looked at.
+		tok = toNFC(tok)
+		id, ok := lookupToken(tok)
+		if !ok {
+			if tokens == 0 {
+				return nil, unknownFirstTokenError(toNFC(dogSpeech))
+			}
+			return nil, fmt.Errorf("unknown token: %q", tok)
+		}
+		tokens++
+
+		bitBuf = (bitBuf << 6) | uint32(id&0x3F)
+		bitCount += 6
+		if bitCount >= 8 {
+			shift := bitCount - 8
+			bytesOut = append(bytesOut, byte((bitBuf>>shift)&0xFF))
+			bitCount -= 8
+			bitBuf = bitBuf & ((1 << bitCount) - 1)
+		}
+		if need < 0 && len(bytesOut) >= 4 {
+			need = 4 + int(binary.BigEndian.Uint32(bytesOut[:4]))
+		}
+	}
+
+	return unframe(bytesOut, tokens)
+}
+
+// CompactSpaces turns the invisible separators that rich-text editors insert
+// between tokens (zero-width space, word joiner, BOM) and every Unicode space
+// separator (category Zs) into plain spaces. Zs characters already split tokens
+// in Decode; the zero-width ones would otherwise glue tokens together.
+func CompactSpaces(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\u200B', r == '\u2060', r == '\uFEFF', unicode.Is(unicode.Zs, r):
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// wrapPairs are the quote and bracket pairs lenient decoding peels off.
+var wrapPairs = [][2]string{
+	{`"`, `"`}, {"'", "'"}, {"`", "`"}, {"“", "”"}, {"‘", "’"},
+	{"「", "」"}, {"『", "』"}, {"(", ")"}, {"[", "]"}, {"{", "}"}, {"<", ">"},
+}
+
+// tokenWrapChars may cling to single tokens copied out of code, e.g. "汪", in a JSON array.
+const tokenWrapChars = "\"'`“”‘’「」『』()[]{}<>,"
+
+// TrimWrapping peels matching quotes or brackets off the whole input, as left
+// behind when dog speech is copied out of JSON or source code, and then strips
+// stray quote, bracket and comma characters from the ends of each token.
+// None of these characters appear in the codebook.
+func TrimWrapping(s string) string {
+	s = strings.TrimSpace(s)
+	for trimmed := true; trimmed; {
+		trimmed = false
+		for _, p := range wrapPairs {
+			if len(s) >= len(p[0])+len(p[1]) && strings.HasPrefix(s, p[0]) && strings.HasSuffix(s, p[1]) {
+				s = strings.TrimSpace(s[len(p[0]) : len(s)-len(p[1])])
+				trimmed = true
+			}
+		}
+	}
 
+	fields := strings.Fields(s)
+	for i, f := range fields {
+		fields[i] = strings.Trim(f, tokenWrapChars)
+	}
+	return strings.Join(fields, " ")
+}
+
+// TrimTrailingNoise repairs a final token that picked up sentence punctuation or
+// an emoji, e.g. "汪汪！。" or "嗚~😀". It only touches the last token, and only
+// when that token is unknown: trailing punctuation and symbols are dropped one
+// rune at a time until a codebook token remains. Because "." "~" "!" and their
+// fullwidth forms are also tones, the longest valid token wins: "汪~.." becomes
+// "汪~." (tone "~."), never "汪~" or "汪".
+func TrimTrailingNoise(dogSpeech string) string {
+	body := strings.TrimRightFunc(dogSpeech, unicode.IsSpace)
+	start := strings.LastIndexFunc(body, unicode.IsSpace) + 1
+	last := body[start:]
+	if _, ok := lookupToken(toNFC(last)); ok {
+		return dogSpeech
+	}
+	for tok := last; tok != ""; {
+		r, size := utf8.DecodeLastRuneInString(tok)
+		if !unicode.IsPunct(r) && !unicode.IsSymbol(r) && r != '\uFE0F' {
+			break
+		}
+		tok = tok[:len(tok)-size]
+		if _, ok := lookupToken(toNFC(tok)); ok {
+			return body[:start] + tok
+		}
+	}
+	return dogSpeech
+}
+
+// FixDuplicates repairs tokens that a mobile keyboard's autocorrect stuttered
+// on: an unknown token with a doubled rune ("汪汪汪~", "嗚汪!!") loses the
+// fewest repeats that make it a codebook token, and one typed twice with no
+// space between ("嗚汪嗚汪") is read once. Known tokens are never touched, and
+// each fix is returned as a warning. This is a guess: "汪汪汪" may as well have
+// been "汪汪 汪" with a lost space, and a doubled rune that happens to make
+// another valid token ("汪" to "汪汪") cannot be seen at all, so a fixed input
+// can decode to the wrong text or fail the length check later.
+func FixDuplicates(dogSpeech string) (string, []string) {
+	var warnings []string
+	fields := strings.Fields(dogSpeech)
+	for i, f := range fields {
+		tok := toNFC(f)
+		if _, ok := lookupToken(tok); ok || strings.Contains(tok, runMark) {
+			continue
+		}
+		fixed := ""
+		if half := tok[:len(tok)/2]; len(tok)%2 == 0 && tok[len(half):] == half {
+			if _, ok := lookupToken(half); ok {
+				fixed = half
+			}
+		}
+		if fixed == "" {
+			fixed = undouble(tok)
+		}
+		if fixed != "" {
+			warnings = append(warnings, fmt.Sprintf("token %d %q read as %q", i, f, fixed))
+			fields[i] = fixed
+		}
+	}
+	if warnings == nil {
+		return dogSpeech, nil
+	}
+	return strings.Join(fields, " "), warnings
+}
+
+// maxUndouble bounds how many repeated runes undouble drops from one token.
+const maxUndouble = 4
+
+// undouble returns the codebook token reached from tok by dropping the fewest
+// runes that repeat the rune before them, or "" if there is none.
+func undouble(tok string) string {
+	// Codebook tokens are at most 4 runes, so longer fields can't be repaired.
+	if utf8.RuneCountInString(tok) > 4+maxUndouble {
+		return ""
+	}
+	level := []string{tok}
+	for range maxUndouble {
+		var next []string
+		for _, t := range level {
+			prev := utf8.RuneError
+			for j, r := range t {
+				if r == prev {
+					c := t[:j] + t[j+utf8.RuneLen(r):]
+					if _, ok := lookupToken(c); ok {
+						return c
+					}
+					next = append(next, c)
+				}
+				prev = r
+			}
+		}
+		if len(next) == 0 {
+			break
+		}
+		level = next
+	}
+	return ""
+}
+
+// Canonicalize returns the one form Encode would produce for the payload that
+// dogSpeech carries: armor, rich-text spaces, surrounding quotes or brackets and
+// token×count runs are undone, the frame is decoded and then encoded again. So
+// two strings canonicalize equally exactly when they decode to the same bytes,
+// whatever their padding bits, trailing tokens or pad token.
+func Canonicalize(dogSpeech string) (string, error) {
+	s, tokens, err := Dearmor(dogSpeech)
+	if err != nil {
+		return "", err
+	}
+	s, err = ExpandRuns(TrimWrapping(CompactSpaces(s)))
+	if err != nil {
+		return "", err
+	}
+	if err := checkArmorTokens(tokens, s, false); err != nil {
+		return "", err
+	}
+	payload, err := DecodeBytes(s)
+	if err != nil {
+		return "", err
+	}
+	return EncodeBytes(payload), nil
+}
+
+// checkASCIISpaces rejects any separator other than ASCII space, tab, CR or LF,
+// for callers that want to notice rich-text paste instead of tolerating it.
+func checkASCIISpaces(s string) error {
+	for i, r := range s {
+		if r > unicode.MaxASCII && (unicode.IsSpace(r) || r == '\u200B' || r == '\u2060' || r == '\uFEFF') {
+			return fmt.Errorf("non-ASCII separator %U at byte %d", r, i)
+		}
+	}
+	return nil
+}
+
+// nextToken splits the first whitespace-separated field off s, one strings.Fields step at a time.
+func nextToken(s string) (tok, rest string) {
+	s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	i := strings.IndexFunc(s, unicode.IsSpace)
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
+
+// minHeaderTokens is how many 6-bit tokens it takes to carry the 4-byte length header.
+const minHeaderTokens = 6
+
+// shortError reports a stream too short to hold even the length header.
+func shortError(tokens int) error {
+	return fmt.Errorf("decoded data too short (missing length header): got %d tokens, need at least %d", tokens, minHeaderTokens)
+}
+
+// incompleteError reports a frame whose header declares need payload bytes when only have arrived.
+func incompleteError(need uint64, have, tokens int) error {
+	return fmt.Errorf("decoded data incomplete: need %d bytes payload, have %d (%d tokens parsed, %.1f%% of payload recovered)",
+		need, have, tokens, 100*float64(have)/float64(need))
+}
+
+// unframe checks the length header of the decoded bytes and returns the payload it declares.
+// tokens is how many tokens produced bytesOut and only feeds the truncation error.
+func unframe(bytesOut []byte, tokens int) ([]byte, error) {
 	// Need at least 4 bytes for length header
 	if len(bytesOut) < 4 {
-		return "", errors.New("decoded data too short (missing length header)")
+		return nil, shortError(tokens)
 	}
 	n := binary.BigEndian.Uint32(bytesOut[:4])
 	if int64(n) < 0 {
-		return "", errors.New("invalid length header")
+		return nil, errors.New("invalid length header")
 	}
 
 	if len(bytesOut) < 4+int(n) {
-		return "", fmt.Errorf("decoded data incomplete: need %d bytes payload, have %d", n, len(bytesOut)-4)
+		return nil, incompleteError(uint64(n), len(bytesOut)-4, tokens)
 	}
 
-	payload := bytesOut[4 : 4+int(n)]
-	if !utf8.Valid(payload) {
-		return "", errors.New("decoded payload is not valid UTF-8 (token stream may be corrupted)")
-	}
+	// Only the header decides the length. Zero bits left over from the last
+	// token are dropped here, but NUL bytes inside the payload are not, so an
+	// all-zero payload (encoded as nothing but "汪") comes back with its exact length.
+	return bytesOut[4 : 4+int(n)], nil
+}
 
-	return string(payload), nil
+// EncodeKeepNewlines is like Encode, but it ends an output line after the token
+// that completes each newline byte of the input, so multi-line text still looks
+// multi-line. Decode treats line breaks like any other separator, so the result
+// decodes to the exact original.
+func EncodeKeepNewlines(input string) (string, error) {
+	input = toNFC(input)
+	out, err := Encode(input)
+	if err != nil {
+		return "", err
+	}
+	return breakAtNewlines(out, []byte(input)), nil
 }
 
-func readAllStdin() (string, error) {
-	in := bufio.NewReader(os.Stdin)
-	b, err := in.ReadBytes(0)
-	if err == nil {
-		// unlikely to hit NUL; just in case
-		return string(b[:len(b)-1]), nil
+// breakAtNewlines swaps the separator after the token holding the last bit of
+// each '\n' in payload for a line break. out must be the single-spaced encoding of payload.
+func breakAtNewlines(out string, payload []byte) string {
+	breaks := make(map[int]bool)
+	for i, b := range payload {
+		if b == '\n' {
+			// Payload byte i occupies bits [8*(4+i), 8*(4+i)+8) of the frame.
+			breaks[(8*(4+i)+7)/6] = true
+		}
+	}
+	if len(breaks) == 0 {
+		return out
 	}
-	// If ReadBytes returns error, it usually includes partial data; fall back to ReadString loop
-	// Simpler: read via scanner with big buffer
-	sc := bufio.NewScanner(os.Stdin)
-	// allow large inputs
-	buf := make([]byte, 0, 1024*1024)
-	sc.Buffer(buf, 10*1024*1024)
+
 	var sb strings.Builder
-	first := true
-	for sc.Scan() {
-		if !first {
-			sb.WriteByte('\n')
+	sb.Grow(len(out))
+	for i, tok := range strings.Split(out, " ") {
+		if i > 0 {
+			if breaks[i-1] {
+				sb.WriteByte('\n')
+			} else {
+				sb.WriteByte(' ')
+			}
 		}
-		first = false
-		sb.WriteString(sc.Text())
+		sb.WriteString(tok)
 	}
-	if err := sc.Err(); err != nil {
+	return sb.String()
+}
+
+// IsWoofSpeech reports whether s consists solely of codebook tokens, i.e. it
+// looks like something Encode produced.
+func IsWoofSpeech(s string) bool {
+	s = toNFC(s)
+	tok, rest := nextToken(s)
+	if tok == "" {
+		return false
+	}
+	for ; tok != ""; tok, rest = nextToken(rest) {
+		if _, ok := lookupToken(tok); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// ExtractWoofSpeech finds the longest run of consecutive codebook tokens in s,
+// as when dog speech is pasted into the middle of a message, and returns that
+// part of s. Run-compacted tokens (token×count) and the pad token count as
+// tokens. It reports false when s contains no token at all.
+func ExtractWoofSpeech(s string) (string, bool) {
+	s = toNFC(s)
+	isToken := func(f string) bool {
+		if f == PadToken {
+			return true
+		}
+		tok, count, compacted := strings.Cut(f, runMark)
+		if _, ok := lookupToken(tok); !ok {
+			return false
+		}
+		if compacted {
+			n, err := strconv.Atoi(count)
+			return err == nil && n >= 1
+		}
+		return true
+	}
+
+	bestStart, bestEnd, bestLen := 0, 0, 0
+	runStart, runLen := 0, 0
+	for tok, rest := nextToken(s); tok != ""; tok, rest = nextToken(rest) {
+		end := len(s) - len(rest)
+		if !isToken(tok) {
+			runLen = 0
+			continue
+		}
+		if runLen == 0 {
+			runStart = end - len(tok)
+		}
+		runLen++
+		if runLen > bestLen {
+			bestStart, bestEnd, bestLen = runStart, end, runLen
+		}
+	}
+	return s[bestStart:bestEnd], bestLen > 0
+}
+
+// tokensFor returns how many tokens encode a payload of n bytes, header included.
+func tokensFor(n int) int {
+	return ((4+n)*8 + 5) / 6
+}
+
+// TokensNeededForBytes returns how many leading tokens of a frame must arrive
+// before its first n payload bytes can be decoded, e.g. with DecodePartial.
+// For the first rune, n is its UTF-8 width (1-4), known from its first byte;
+// a negative n is treated as 0.
+func TokensNeededForBytes(n int) int {
+	return tokensFor(max(n, 0))
+}
+
+func readAllStdin(stdin io.Reader) (string, error) {
+	b, err := io.ReadAll(stdin)
+	if err != nil {
+		return "", readError("stdin", err)
+	}
+	return string(b), nil
+}
+
+// readError names the input source in a read failure, as in "read stdin: ..." or
+// "read file foo.txt: ...". The os.PathError wrapper is dropped since it would
+// repeat the path.
+func readError(source string, err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return fmt.Errorf("read %s: %w", source, err)
+}
+
+// isGzipPath reports whether path names a gzip file, judged by its extension.
+func isGzipPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".gz")
+}
+
+// readFile reads the whole file, transparently gunzipping it when the name ends in ".gz".
+// A non-nil progress gets a percentage of the (compressed) file size as it is read.
+func readFile(path string, progress io.Writer) (string, error) {
+	r, done, err := openFile(path, progress)
+	if err != nil {
 		return "", err
 	}
-	return sb.String(), nil
+	defer done()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", readError("file "+path, err)
+	}
+	return string(b), nil
 }
 
-func inputFromArgsOrStdin(args []string) (string, error) {
+// openFile opens path for reading like readFile, without reading it. done
+// closes the file.
+func openFile(path string, progress io.Writer) (r io.Reader, done func(), err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, readError("file "+path, err)
+	}
+
+	r = f
+	if progress != nil {
+		if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() && fi.Size() > 0 {
+			r = newProgressReader(f, progress, fi.Size())
+		}
+	}
+	if isGzipPath(path) {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			f.Close()
+			return nil, nil, readError("file "+path, err)
+		}
+		return zr, func() { zr.Close(); f.Close() }, nil
+	}
+	return r, func() { f.Close() }, nil
+}
+
+// writeResult prints out followed by a newline to w, or to the file at path when it is set.
+// Files ending in ".gz" are gzipped.
+func writeResult(w io.Writer, path string, out string) error {
+	return writeRaw(w, path, []byte(out+"\n"))
+}
+
+// writeRaw is writeResult for bytes that must arrive exactly as given, with no newline added.
+func writeRaw(w io.Writer, path string, data []byte) error {
+	if path == "" {
+		_, err := w.Write(data)
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	var dst io.Writer = f
+	var zw *gzip.Writer
+	if isGzipPath(path) {
+		zw = gzip.NewWriter(f)
+		dst = zw
+	}
+	if _, err := dst.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// typewrite prints out to w one token at a time, pausing delay before each
+// token after the first, and ends with a newline like writeResult.
+func typewrite(w io.Writer, out string, delay time.Duration) error {
+	for i, tok := range strings.SplitAfter(out, " ") {
+		if i > 0 {
+			time.Sleep(delay)
+		}
+		if _, err := io.WriteString(w, tok); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// fileSeparator is written between files by encode --files (ASCII record separator),
+// so the decoded text can be split back into the original files.
+const fileSeparator = "\x1e"
+
+// readFiles reads every path with readFile and joins the contents with fileSeparator.
+func readFiles(paths []string, progress io.Writer) (string, error) {
+	contents := make([]string, len(paths))
+	for i, path := range paths {
+		c, err := readFile(path, progress)
+		if err != nil {
+			return "", err
+		}
+		contents[i] = c
+	}
+	return strings.Join(contents, fileSeparator), nil
+}
+
+// inputFromArgsOrStdin picks the input: the file when one is given, else the args, else stdin.
+func inputFromArgsOrStdin(args []string, file string, progress io.Writer, stdin io.Reader) (string, error) {
+	if file != "" {
+		if len(args) > 0 {
+			return "", errors.New("cannot combine --file with text arguments")
+		}
+		return readFile(file, progress)
+	}
 	if len(args) > 0 {
 		return strings.Join(args, " "), nil
 	}
-	return readAllStdin()
+	return readAllStdin(stdin)
 }
 
 func runMode(mode string, input string) (string, error) {
@@ -219,65 +1283,741 @@ func runMode(mode string, input string) (string, error) {
 	}
 }
 
+// decodeBase64URL decodes base64url text ('-' and '_' alphabet), with or without '=' padding.
+func decodeBase64URL(s string) ([]byte, error) {
+	s = strings.TrimRight(strings.TrimSpace(s), "=")
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64url input: %w", err)
+	}
+	return b, nil
+}
+
+// verifyDecode decodes output for encode --verify. It is a variable so the
+// check itself can be exercised with a deliberately wrong decoder.
+var verifyDecode = DecodeBytes
+
+// verifyRoundTrip decodes out with decode and checks that it yields payload again.
+func verifyRoundTrip(decode func(string) ([]byte, error), out string, payload []byte) error {
+	got, err := decode(out)
+	if err != nil {
+		return fmt.Errorf("round-trip verification failed: %w", err)
+	}
+	if !bytes.Equal(got, payload) {
+		return fmt.Errorf("round-trip verification failed: decoded %d bytes differ from the %d input bytes", len(got), len(payload))
+	}
+	return nil
+}
+
+// decodeLines decodes every line of r independently and writes one result per
+// line, to w or to the file at path. A line that fails becomes an "[error]"
+// marker and decoding carries on; the returned error counts the failures.
+func decodeLines(r io.Reader, w io.Writer, path string, decode func(string) (string, error)) error {
+	var sb strings.Builder
+	dst := w
+	if path != "" {
+		dst = &sb
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	total, failed := 0, 0
+	for sc.Scan() {
+		total++
+		out, err := decode(sc.Text())
+		if err != nil {
+			failed++
+			out = fmt.Sprintf("[error] line %d: %v", total, err)
+		}
+		if _, err := fmt.Fprintln(dst, out); err != nil {
+			return fmt.Errorf("write output error: %w", err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("read input error: %w", err)
+	}
+
+	if path != "" {
+		if err := writeResult(w, path, strings.TrimSuffix(sb.String(), "\n")); err != nil {
+			return fmt.Errorf("write output error: %w", err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("decode error: %d of %d lines failed", failed, total)
+	}
+	return nil
+}
+
+// streamPeekSize is how much of a file streamDecodeFile looks at before
+// deciding it is plain tokens.
+const streamPeekSize = 4096
+
+// streamDecodeFile decodes the dog speech in the file at path with a Decoder,
+// writing the payload to w as it comes instead of holding the file and the
+// payload in memory, then a newline unless raw is set. It only takes files
+// that start with plain whitespace-separated tokens; armored, prettified,
+// colored or otherwise wrapped input reports ok == false before anything is
+// written, so the caller can fall back to decoding it in one piece. With first
+// set, input after the frame is not read. A broken stream fails after the
+// payload so far has been written.
+func streamDecodeFile(w io.Writer, path string, progress io.Writer, checkUTF8, first, raw bool) (n int64, ok bool, err error) {
+	f, done, err := openFile(path, progress)
+	if err != nil {
+		return 0, false, fmt.Errorf("read input error: %w", err)
+	}
+	defer done()
+
+	br := bufio.NewReaderSize(f, streamPeekSize)
+	head, err := br.Peek(streamPeekSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return 0, false, fmt.Errorf("read input error: %w", readError("file "+path, err))
+	}
+	fields := strings.Fields(string(head))
+	if len(head) == streamPeekSize && len(fields) > 0 {
+		fields = fields[:len(fields)-1] // may be cut off
+	}
+	if len(fields) == 0 {
+		return 0, false, nil
+	}
+	for _, f := range fields {
+		tok, _, err := parseRun(toNFC(f))
+		if _, known := lookupToken(tok); err != nil || (!known && tok != PadToken) {
+			return 0, false, nil
+		}
+	}
+
+	d := NewDecoder(br)
+	if checkUTF8 {
+		d = NewUTF8Decoder(br)
+	}
+	d.runs = true // the one-shot path expands runs too
+	bw := bufio.NewWriter(w)
+	n, err = io.Copy(bw, d)
+	if err == nil && !first {
+		err = d.drain()
+	}
+	if err != nil {
+		bw.Flush()
+		return n, true, fmt.Errorf("decode error: %w", err)
+	}
+	if !raw {
+		bw.WriteByte('\n')
+	}
+	if err := bw.Flush(); err != nil {
+		return n, true, fmt.Errorf("write output error: %w", err)
+	}
+	return n, true, nil
+}
+
+// perRuneLines lists each rune of text on its own line as "U+XXXX<TAB>rune";
+// runes that don't print (newlines, controls) are shown quoted.
+func perRuneLines(text string) string {
+	lines := make([]string, 0, utf8.RuneCountInString(text))
+	for _, r := range text {
+		shown := string(r)
+		if !unicode.IsPrint(r) {
+			shown = strconv.QuoteRune(r)
+		}
+		lines = append(lines, fmt.Sprintf("%U\t%s", r, shown))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// longestLine returns the 1-based number and rune length of the longest line in s.
+func longestLine(s string) (line, length int) {
+	for i, l := range strings.Split(s, "\n") {
+		if n := utf8.RuneCountInString(l); n > length {
+			line, length = i+1, n
+		}
+	}
+	return line, length
+}
+
+// newLogger returns a stderr logger whose level follows the -v count:
+// warnings by default, info at -v and debug at -vv.
+func newLogger(w io.Writer, verbosity int) *slog.Logger {
+	level := slog.LevelWarn
+	switch {
+	case verbosity >= 2:
+		level = slog.LevelDebug
+	case verbosity == 1:
+		level = slog.LevelInfo
+	}
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level}))
+}
+
+// countTokens counts the whitespace-separated tokens in s without splitting it.
+func countTokens(s string) int {
+	n := 0
+	for tok, rest := nextToken(s); tok != ""; tok, rest = nextToken(rest) {
+		n++
+	}
+	return n
+}
+
 func newRootCmd() *cobra.Command {
 	var mode string
+	var inFile, outFile string
+	var verbosity int
+	var toClipboard, showProgress, typewriter, deterministic, escape, qr, littleEndian bool
+	var typeDelay time.Duration
+	logger := slog.New(slog.DiscardHandler)
+
+	// progressTo is where file reads report progress: stderr with --progress when
+	// it is a terminal, otherwise nowhere.
+	progressTo := func(cmd *cobra.Command) io.Writer {
+		if showProgress && isTerminal(cmd.ErrOrStderr()) {
+			return cmd.ErrOrStderr()
+		}
+		return nil
+	}
+
+	// emit writes a result to stdout or --output, and also to the clipboard with --clipboard.
+	emit := func(cmd *cobra.Command, out string) error {
+		write := writeResult
+		if typewriter && outFile == "" && isTerminal(cmd.OutOrStdout()) {
+			write = func(w io.Writer, _ string, out string) error { return typewrite(w, out, typeDelay) }
+		}
+		if err := write(cmd.OutOrStdout(), outFile, out); err != nil {
+			return fmt.Errorf("write output error: %w", err)
+		}
+		if toClipboard {
+			if err := clipboardWriter(out); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "warning: could not copy the result to the clipboard: %v\n", err)
+			}
+		}
+		return nil
+	}
 
 	rootCmd := &cobra.Command{
 		Use:   "woofwoof [text]",
 		Short: "Encode/decode text as dog speech",
 		Args:  cobra.ArbitraryArgs,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(configPath())
+			if err == nil {
+				err = applyDefaults(cmd, cfg)
+			}
+			logger = newLogger(cmd.ErrOrStderr(), verbosity)
+			return err
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			input, err := inputFromArgsOrStdin(args)
+			input, err := inputFromArgsOrStdin(args, inFile, progressTo(cmd), cmd.InOrStdin())
 			if err != nil {
-				return fmt.Errorf("read stdin error: %w", err)
+				return fmt.Errorf("read input error: %w", err)
 			}
+			if !cmd.Flags().Changed("mode") && IsWoofSpeech(input) {
+				fmt.Fprintln(cmd.ErrOrStderr(), "hint: input looks like dog speech but the default mode is encode; did you mean --mode decode?")
+			}
+			start := time.Now()
 			out, err := runMode(mode, input)
 			if err != nil {
 				return err
 			}
-			fmt.Fprintln(cmd.OutOrStdout(), out)
-			return nil
+			logger.Info("done", "mode", mode, "input_bytes", len(input), "output_bytes", len(out), "elapsed", time.Since(start))
+			return emit(cmd, out)
 		},
 	}
 	rootCmd.Flags().StringVarP(&mode, "mode", "m", "encode", "encode or decode")
+	rootCmd.PersistentFlags().StringVarP(&inFile, "file", "f", "", "read input from file instead of args/stdin (.gz is gunzipped)")
+	rootCmd.PersistentFlags().BoolVar(&toClipboard, "clipboard", false, "also copy the result to the system clipboard")
+	rootCmd.PersistentFlags().BoolVar(&showProgress, "progress", false, "show how much of --file has been read on stderr (terminal only)")
+	rootCmd.PersistentFlags().CountVarP(&verbosity, "verbose", "v", "log details to stderr (-v info, -vv debug)")
+	rootCmd.PersistentFlags().StringVarP(&outFile, "output", "o", "", "write result to file instead of stdout (.gz is gzipped)")
 
+	var base64URL, spaceless bool
+	var watchPath, preset, colorMode, groupSpec, cover, delimiter string
+	var verifyUTF8, noDoubleEncode, stripBOM, keepNewlines, printIDs, argFiles, verify, showVerify, padToken, rle, armor, pretty bool
+	var style, normForm string
+	var padTo, maxLineLength int
 	encodeCmd := &cobra.Command{
 		Use:   "encode [text]",
 		Short: "Encode plain UTF-8 text to dog speech",
 		Args:  cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			input, err := inputFromArgsOrStdin(args)
+			if watchPath != "" {
+				if inFile != "" || argFiles || len(args) > 0 {
+					return errors.New("--watch reads its own file; drop --file, --files and text arguments")
+				}
+				// Each run is a plain encode of the watched file.
+				inFile, watchPath = watchPath, ""
+				return watch(cmd.Context(), inFile, func() error { return cmd.RunE(cmd, args) }, logger)
+			}
+			var input string
+			var err error
+			if argFiles {
+				if inFile != "" {
+					return errors.New("cannot combine --files with --file")
+				}
+				if len(args) == 0 {
+					return errors.New("--files needs at least one path")
+				}
+				input, err = readFiles(args, progressTo(cmd))
+			} else {
+				input, err = inputFromArgsOrStdin(args, inFile, progressTo(cmd), cmd.InOrStdin())
+			}
 			if err != nil {
-				return fmt.Errorf("read stdin error: %w", err)
+				return fmt.Errorf("read input error: %w", err)
+			}
+			if padTo < 0 {
+				return errors.New("--pad-to must not be negative")
+			}
+			if maxLineLength < 0 {
+				return errors.New("--max-line-length must not be negative")
+			}
+			if typeDelay < 0 {
+				return errors.New("--typewriter-delay must not be negative")
+			}
+			if padTo > 0 && padToken {
+				// The pad token must follow the frame's last token, which --pad-to moves.
+				return errors.New("--pad-token cannot be combined with --pad-to")
+			}
+			switch colorMode {
+			case "never", "auto", "always":
+			default:
+				return fmt.Errorf("unknown --color %q (want never, auto or always)", colorMode)
+			}
+			if colorMode != "never" && (printIDs || spaceless) {
+				return errors.New("--color cannot be combined with --ids or --spaceless")
+			}
+			if padToken && printIDs {
+				return errors.New("--pad-token cannot be combined with --ids: the pad token has no id")
+			}
+			if qr && (spaceless || printIDs || rle || keepNewlines || pretty || padToken || armor || style != "plain" || preset != "" || groupSpec != "" || colorMode != "never") {
+				return errors.New("--qr cannot be combined with --spaceless, --ids, --rle, --keep-newlines, --pretty, --pad-token, --armor, --style, --preset, --group or --color")
+			}
+			if cmd.Flags().Changed("hide-in") && (spaceless || printIDs || rle || keepNewlines || pretty || padToken || armor || qr || escape || style != "plain" || preset != "" || groupSpec != "" || colorMode != "never") {
+				return errors.New("--hide-in cannot be combined with --spaceless, --ids, --rle, --keep-newlines, --pretty, --pad-token, --armor, --qr, --escape, --style, --preset, --group or --color")
+			}
+			if delimiter != "" {
+				if spaceless || printIDs || qr || pretty || padToken || groupSpec != "" || colorMode != "never" || cmd.Flags().Changed("hide-in") {
+					return errors.New("--delimiter cannot be combined with --spaceless, --ids, --qr, --pretty, --pad-token, --group, --color or --hide-in")
+				}
+			}
+			if littleEndian && (spaceless || padTo > 0 || qr) {
+				return errors.New("--little-endian cannot be combined with --spaceless, --pad-to or --qr")
+			}
+			if escape && colorMode != "never" {
+				return errors.New("--escape cannot be combined with --color")
+			}
+			if groupSpec != "" && (pretty || keepNewlines || spaceless) {
+				return errors.New("--group cannot be combined with --pretty, --keep-newlines or --spaceless")
+			}
+			if preset != "" && (rle || spaceless) {
+				return errors.New("--preset cannot be combined with --rle or --spaceless")
+			}
+			if spaceless && (padTo > 0 || padToken || printIDs || rle || keepNewlines || pretty || style != "plain") {
+				return errors.New("--spaceless cannot be combined with --pad-to, --pad-token, --ids, --rle, --keep-newlines, --pretty or --style")
+			}
+			if deterministic {
+				// Both depend on the terminal, not only on the input.
+				if colorMode != "never" {
+					fmt.Fprintf(cmd.ErrOrStderr(), "warning: --deterministic overrides --color %s\n", colorMode)
+					colorMode = "never"
+				}
+				if typewriter {
+					fmt.Fprintln(cmd.ErrOrStderr(), "warning: --deterministic overrides --typewriter")
+					typewriter = false
+				}
+			}
+			normalize, err := normalizer(normForm)
+			if err != nil {
+				return err
+			}
+			renderer, err := lookupRenderer(style)
+			if err != nil {
+				return err
+			}
+			if preset != "" {
+				if renderer != nil {
+					return errors.New("--preset cannot be combined with --style")
+				}
+				if renderer, err = lookupPreset(preset); err != nil {
+					return err
+				}
+			}
+			if delimiter != "" {
+				if err := checkSeparator(delimiter, renderer); err != nil {
+					return fmt.Errorf("--delimiter: %w", err)
+				}
+			}
+			logger.Debug("encode options", "verify_utf8", verifyUTF8, "norm", normForm, "no_double_encode", noDoubleEncode, "strip_bom", stripBOM, "style", style, "file", inFile, "output", outFile)
+			if stripBOM {
+				input = strings.TrimPrefix(input, utf8BOM)
+			}
+			if base64URL {
+				raw, err := decodeBase64URL(input)
+				if err != nil {
+					return fmt.Errorf("encode error: %w", err)
+				}
+				input = string(raw)
+			}
+			if IsWoofSpeech(input) {
+				if noDoubleEncode {
+					return errors.New("encode error: input is already dog speech (did you mean decode?)")
+				}
+				fmt.Fprintln(cmd.ErrOrStderr(), "warning: input already looks like dog speech; encoding it again")
+			}
+			start := time.Now()
+			var out string
+			if verifyUTF8 && !base64URL {
+				// Normalize and validate here, as Encode would, but with the chosen form.
+				input = normalize(input)
+				if !utf8.ValidString(input) {
+					return fmt.Errorf("encode error: %w", errInvalidInput)
+				}
+			}
+			switch {
+			case spaceless:
+				out = EncodeSpacelessBytes([]byte(input))
+			case littleEndian:
+				out = EncodeBytesLE([]byte(input))
+			case padTo > 0:
+				out, err = EncodeBytesPadded([]byte(input), padTo)
+			default:
+				out = EncodeBytes([]byte(input))
 			}
-			out, err := Encode(input)
 			if err != nil {
 				return fmt.Errorf("encode error: %w", err)
 			}
-			fmt.Fprintln(cmd.OutOrStdout(), out)
+			frameTokens := armorTokens(out, spaceless)
+			var verifyErr error
+			if verify || showVerify {
+				decode := verifyDecode
+				switch {
+				case spaceless:
+					decode = DecodeSpacelessBytes
+				case littleEndian:
+					decode = DecodeBytesLE
+				}
+				verifyErr = verifyRoundTrip(decode, out, []byte(input))
+				if verify && verifyErr != nil {
+					return fmt.Errorf("encode error: %w", verifyErr)
+				}
+			}
+			if printIDs {
+				ids, err := DecodeToIDs(out)
+				if err != nil {
+					return fmt.Errorf("encode error: %w", err)
+				}
+				out = formatIDs(ids)
+			}
+			if padToken {
+				out += " " + PadToken
+			}
+			if keepNewlines {
+				out = breakAtNewlines(out, []byte(input))
+			}
+			if rle && !printIDs {
+				out = CompactRuns(out)
+			}
+			if renderer != nil && !printIDs {
+				out = renderTokens(out, renderer)
+			}
+			if pretty && !printIDs {
+				out = Prettify(out)
+			}
+			if groupSpec != "" {
+				group, line, err := parseGroup(groupSpec)
+				if err != nil {
+					return err
+				}
+				out = GroupTokens(out, group, line)
+			}
+			if delimiter != "" {
+				out = strings.ReplaceAll(out, " ", delimiter)
+			}
+			if qr {
+				if out, err = QRForm(out); err != nil {
+					return fmt.Errorf("encode error: %w", err)
+				}
+			}
+			if cmd.Flags().Changed("hide-in") {
+				if out, err = HideInCover(out, cover); err != nil {
+					return fmt.Errorf("encode error: %w", err)
+				}
+			}
+			if escape {
+				out = EscapeTokens(out)
+			}
+			logger.Info("encoded", "payload_bytes", len(input), "tokens", countTokens(out), "elapsed", time.Since(start))
+			if maxLineLength > 0 {
+				if line, n := longestLine(out); n > maxLineLength {
+					fmt.Fprintf(cmd.ErrOrStderr(), "warning: output line %d is %d characters, over --max-line-length %d; shorten the input or split it across messages\n",
+						line, n, maxLineLength)
+				}
+			}
+			if colorMode == "always" || (colorMode == "auto" && outFile == "" && isTerminal(cmd.OutOrStdout())) {
+				out = colorizeTokens(out, func(field string) (byte, bool) { return fieldID(field, renderer) })
+			}
+			if armor {
+				out = Armor(out, frameTokens)
+			}
+			if err := emit(cmd, out); err != nil {
+				return err
+			}
+			if showVerify {
+				if verifyErr != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "verified: failed (%v)\n", verifyErr)
+					return fmt.Errorf("encode error: %w", verifyErr)
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "verified: ok (%d bytes)\n", len(input))
+			}
 			return nil
 		},
 	}
 
+	var expectSHA256 string
+	var extract bool
+	var firstFrame, stream, fixDuplicates, reveal, outputBOM, lines, strictSpaces, lenient, perRune, hexOnInvalid, headerless, fromIDs, binaryOut bool
 	decodeCmd := &cobra.Command{
 		Use:   "decode [dog-speech]",
 		Short: "Decode dog speech back to original UTF-8 text",
 		Args:  cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			input, err := inputFromArgsOrStdin(args)
+			renderer, err := lookupRenderer(style)
+			if err != nil {
+				return err
+			}
+			if preset != "" {
+				if renderer != nil {
+					return errors.New("--preset cannot be combined with --style")
+				}
+				if renderer, err = lookupPreset(preset); err != nil {
+					return err
+				}
+			}
+			if delimiter != "" {
+				if err := checkSeparator(delimiter, renderer); err != nil {
+					return fmt.Errorf("--delimiter: %w", err)
+				}
+			}
+			if expectSHA256 != "" {
+				if b, err := hex.DecodeString(expectSHA256); err != nil || len(b) != sha256.Size {
+					return errors.New("--expect-sha256 must be 64 hex digits")
+				}
+			}
+			if littleEndian && (spaceless || headerless || fromIDs || firstFrame) {
+				return errors.New("--little-endian cannot be combined with --spaceless, --legacy-headerless, --from-ids or --first")
+			}
+			if qr && (spaceless || headerless || fromIDs || renderer != nil) {
+				return errors.New("--qr cannot be combined with --spaceless, --legacy-headerless, --from-ids, --style or --preset")
+			}
+			if binaryOut && (lines || perRune || outputBOM || base64URL || hexOnInvalid || toClipboard) {
+				return errors.New("--binary cannot be combined with --lines, --per-rune, --output-bom, --base64url, --hex-on-invalid or --clipboard")
+			}
+			logger.Debug("decode options", "verify_utf8", verifyUTF8, "first", firstFrame, "lenient", lenient, "output_bom", outputBOM, "style", style, "lines", lines, "file", inFile, "output", outFile)
+
+			decodeOne := func(input string) (string, error) {
+				if reveal {
+					found, err := RevealFromCover(input)
+					if err != nil {
+						return "", err
+					}
+					input = found
+				}
+				input, declared, err := Dearmor(input)
+				if err != nil {
+					return "", err
+				}
+				if input, err = UnescapeTokens(input); err != nil {
+					return "", err
+				}
+				if delimiter != "" {
+					input = strings.ReplaceAll(input, delimiter, " ")
+				}
+				if qr {
+					// Only line endings are trimmed: a space is a QR alphanumeric digit.
+					if input, err = FromQRForm(strings.TrimRight(input, "\r\n")); err != nil {
+						return "", err
+					}
+				}
+				input = Unprettify(StripColors(input))
+				if strictSpaces {
+					if err := checkASCIISpaces(input); err != nil {
+						return "", err
+					}
+				} else {
+					input = CompactSpaces(input)
+				}
+				if renderer != nil {
+					input = renderer.Normalize(input)
+				}
+				if lenient {
+					input = TrimTrailingNoise(TrimWrapping(input))
+				}
+				if fixDuplicates {
+					var fixes []string
+					input, fixes = FixDuplicates(input)
+					for _, fix := range fixes {
+						fmt.Fprintf(cmd.ErrOrStderr(), "warning: guessed an autocorrect duplicate (%s); the result may be wrong\n", fix)
+					}
+				}
+				if extract {
+					found, ok := ExtractWoofSpeech(input)
+					if !ok {
+						return "", errors.New("no dog speech found in the input")
+					}
+					input = found
+				}
+				input, err = ExpandRuns(input)
+				if err != nil {
+					return "", err
+				}
+				if err := checkArmorTokens(declared, input, spaceless); err != nil {
+					return "", err
+				}
+				start := time.Now()
+				decode := DecodeBytes
+				switch {
+				case spaceless:
+					decode = DecodeSpacelessBytes
+				case littleEndian:
+					decode = DecodeBytesLE
+				case headerless:
+					decode = DecodeHeaderlessBytes
+				case fromIDs:
+					decode = func(s string) ([]byte, error) {
+						ids, err := parseIDs(s)
+						if err != nil {
+							return nil, err
+						}
+						return DecodeFromIDs(ids)
+					}
+				case firstFrame:
+					decode = decodeFirstBytes
+				}
+				payload, err := decode(input)
+				if err != nil {
+					return "", err
+				}
+				if expectSHA256 != "" {
+					if sum := sha256.Sum256(payload); !strings.EqualFold(hex.EncodeToString(sum[:]), expectSHA256) {
+						return "", fmt.Errorf("payload SHA-256 is %x, expected %s", sum, strings.ToLower(expectSHA256))
+					}
+				}
+				if base64URL {
+					return base64.RawURLEncoding.EncodeToString(payload), nil
+				}
+				if verifyUTF8 && !binaryOut && !utf8.Valid(payload) {
+					if !hexOnInvalid {
+						return "", errInvalidPayload
+					}
+					fmt.Fprintf(cmd.ErrOrStderr(), "warning: decoded payload (%d bytes) is not valid UTF-8; printing it as a hex dump\n", len(payload))
+					return strings.TrimSuffix(hex.Dump(payload), "\n"), nil
+				}
+				logger.Info("decoded", "tokens", countTokens(input), "payload_bytes", len(payload), "elapsed", time.Since(start))
+				out := string(payload)
+				if perRune {
+					out = perRuneLines(out)
+				}
+				if outputBOM {
+					out = utf8BOM + out
+				}
+				return out, nil
+			}
+
+			if lines {
+				var r io.Reader = cmd.InOrStdin()
+				if inFile != "" || len(args) > 0 {
+					input, err := inputFromArgsOrStdin(args, inFile, progressTo(cmd), cmd.InOrStdin())
+					if err != nil {
+						return fmt.Errorf("read input error: %w", err)
+					}
+					r = strings.NewReader(input)
+				}
+				return decodeLines(r, cmd.OutOrStdout(), outFile, decodeOne)
+			}
+
+			if stream && inFile != "" && len(args) == 0 && outFile == "" && renderer == nil && expectSHA256 == "" &&
+				delimiter == "" && !(reveal || qr || littleEndian || fixDuplicates || spaceless || headerless || fromIDs || lenient || extract || strictSpaces || base64URL || hexOnInvalid || perRune || outputBOM || toClipboard) {
+				start := time.Now()
+				n, ok, err := streamDecodeFile(cmd.OutOrStdout(), inFile, progressTo(cmd), verifyUTF8 && !binaryOut, firstFrame, binaryOut)
+				if ok || err != nil {
+					if err == nil {
+						logger.Info("decoded", "payload_bytes", n, "streamed", true, "elapsed", time.Since(start))
+					}
+					return err
+				}
+				logger.Debug("input is not plain tokens; decoding it in one piece", "file", inFile)
+			}
+
+			input, err := inputFromArgsOrStdin(args, inFile, progressTo(cmd), cmd.InOrStdin())
 			if err != nil {
-				return fmt.Errorf("read stdin error: %w", err)
+				return fmt.Errorf("read input error: %w", err)
 			}
-			out, err := Decode(input)
+			out, err := decodeOne(input)
 			if err != nil {
 				return fmt.Errorf("decode error: %w", err)
 			}
-			fmt.Fprintln(cmd.OutOrStdout(), out)
-			return nil
+			if binaryOut {
+				if err := writeRaw(cmd.OutOrStdout(), outFile, []byte(out)); err != nil {
+					return fmt.Errorf("write output error: %w", err)
+				}
+				return nil
+			}
+			return emit(cmd, out)
 		},
 	}
 
-	rootCmd.AddCommand(encodeCmd, decodeCmd)
+	encodeCmd.Flags().BoolVar(&verifyUTF8, "verify-utf8", true, "reject input that is not valid UTF-8; false encodes the raw bytes as-is")
+	encodeCmd.Flags().BoolVar(&noDoubleEncode, "no-double-encode", false, "fail instead of warning when the input is already dog speech")
+	decodeCmd.Flags().BoolVar(&verifyUTF8, "verify-utf8", true, "reject output that is not valid UTF-8; false prints the raw bytes")
+	encodeCmd.Flags().StringVar(&normForm, "norm", "NFC", "Unicode normalization applied before encoding: NFC, NFD, NFKC, NFKD or none")
+	encodeCmd.Flags().StringVar(&style, "style", "plain", "token style: plain or brackets")
+	encodeCmd.Flags().StringVar(&preset, "preset", "", "speak with another vocabulary: angry, cat or puppy (decode needs the same --preset)")
+	encodeCmd.Flags().IntVar(&padTo, "pad-to", 0, "zero-pad the payload to N bytes so all inputs up to N encode to the same length")
+	encodeCmd.Flags().IntVar(&maxLineLength, "max-line-length", 0, "warn when an output line is longer than N characters (0 disables)")
+	encodeCmd.Flags().BoolVar(&rle, "rle", false, "write runs of "+strconv.Itoa(minRun)+" or more identical tokens as token"+runMark+"count (decode expands them automatically)")
+	encodeCmd.Flags().BoolVar(&base64URL, "base64url", false, "treat the input as base64url (padding optional) and encode the bytes it stands for")
+	encodeCmd.Flags().BoolVar(&typewriter, "typewriter", false, "print the tokens one by one, like a dog typing (only when stdout is a terminal)")
+	encodeCmd.Flags().DurationVar(&typeDelay, "typewriter-delay", 80*time.Millisecond, "pause between tokens with --typewriter")
+	encodeCmd.Flags().BoolVar(&deterministic, "deterministic", false, "make the output depend on the input alone: turns off --color and --typewriter with a warning")
+	encodeCmd.Flags().BoolVar(&escape, "escape", false, `write every non-ASCII character as a \uXXXX escape for ASCII-only logs (decode unescapes automatically)`)
+	encodeCmd.Flags().BoolVar(&qr, "qr", false, "write the frame in the 45-character QR alphanumeric set (Base45) instead of tokens; decode needs --qr")
+	encodeCmd.Flags().BoolVar(&littleEndian, "little-endian", false, "write the length header little-endian, as a buggy encoder would (for testing other decoders; decode needs --little-endian)")
+	encodeCmd.Flags().StringVar(&cover, "hide-in", "", "hide the tokens as zero-width characters inside this cover text (decode needs --reveal)")
+	encodeCmd.Flags().StringVar(&delimiter, "delimiter", "", "put this between tokens instead of a space, for chats that collapse spaces; --delimiter alone means "+defaultDelimiter+" (decode needs the same --delimiter)")
+	encodeCmd.Flags().StringVar(&groupSpec, "group", "", "group tokens for proofreading: SIZE tokens per group, optionally ,LINE tokens per line (e.g. 4,16)")
+	encodeCmd.Flags().StringVar(&colorMode, "color", "never", "color tokens by core: never, auto (only on a terminal) or always; --color alone means auto")
+	encodeCmd.Flags().Lookup("color").NoOptDefVal = "auto"
+	encodeCmd.Flags().Lookup("delimiter").NoOptDefVal = defaultDelimiter
+	encodeCmd.Flags().StringVar(&watchPath, "watch", "", "encode this file, then again whenever it changes (use with -o)")
+	encodeCmd.Flags().BoolVar(&pretty, "pretty", false, "group tokens into pseudo-sentences with ， and 。 (decode strips them automatically)")
+	encodeCmd.Flags().BoolVar(&armor, "armor", false, "wrap the output in BEGIN/END lines with version and token count (decode strips them automatically)")
+	encodeCmd.Flags().BoolVar(&spaceless, "spaceless", false, "write the separator-free form (16 two-rune tokens, 2 tokens per byte)")
+	encodeCmd.Flags().BoolVar(&padToken, "pad-token", false, "end the output with the visible pad token "+PadToken)
+	encodeCmd.Flags().BoolVar(&showVerify, "show-verify", false, "after the output, print \"verified: ok (N bytes)\" (or why it failed) on stderr from decoding it again")
+	encodeCmd.Flags().BoolVar(&verify, "verify", false, "decode the output again and fail unless it matches the input")
+	encodeCmd.Flags().BoolVar(&argFiles, "files", false, "treat args as file paths and encode their contents joined by an ASCII record separator (0x1E)")
+	encodeCmd.Flags().BoolVar(&printIDs, "ids", false, "print the 6-bit token ids (0-63) instead of the tokens")
+	encodeCmd.Flags().BoolVar(&keepNewlines, "keep-newlines", false, "break the output line wherever the input has a newline")
+	encodeCmd.Flags().BoolVar(&stripBOM, "strip-bom", false, "drop a leading UTF-8 BOM (U+FEFF) from the input")
+	decodeCmd.Flags().StringVar(&style, "style", "plain", "token style the input was encoded with: plain or brackets")
+	decodeCmd.Flags().StringVar(&preset, "preset", "", "vocabulary the input was encoded with: angry, cat or puppy")
+	decodeCmd.Flags().BoolVar(&outputBOM, "output-bom", false, "prepend a UTF-8 BOM (U+FEFF) to the decoded text")
+	decodeCmd.Flags().BoolVar(&strictSpaces, "strict-spaces", false, "only accept ASCII whitespace between tokens instead of tolerating rich-text spaces")
+	decodeCmd.Flags().BoolVar(&lenient, "lenient", false, "strip quotes, brackets and commas around the input and its tokens, and punctuation or emoji stuck to the last token")
+	decodeCmd.Flags().BoolVar(&perRune, "per-rune", false, "print each decoded rune on its own line with its code point")
+	decodeCmd.Flags().BoolVar(&base64URL, "base64url", false, "print the decoded bytes as unpadded base64url")
+	decodeCmd.Flags().BoolVar(&hexOnInvalid, "hex-on-invalid", false, "print a hex dump instead of failing when the decoded bytes are not valid UTF-8")
+	decodeCmd.Flags().BoolVar(&spaceless, "spaceless", false, "read the separator-free form written by encode --spaceless")
+	decodeCmd.Flags().StringVar(&expectSHA256, "expect-sha256", "", "fail unless the decoded payload has this SHA-256 (hex)")
+	decodeCmd.Flags().BoolVar(&extract, "extract", false, "decode the longest run of dog-speech tokens in the input and ignore the text around it")
+	decodeCmd.Flags().BoolVar(&binaryOut, "binary", false, "write the decoded bytes exactly as they are: no UTF-8 check and no trailing newline")
+	decodeCmd.Flags().BoolVar(&fromIDs, "from-ids", false, "read space-separated 6-bit ids (0-63), as printed by encode --ids, instead of tokens")
+	decodeCmd.Flags().BoolVar(&headerless, "legacy-headerless", false, "decode a stream without the 4-byte length header; every whole byte is payload and truncation goes undetected")
+	decodeCmd.Flags().BoolVar(&lines, "lines", false, "decode each input line on its own, marking failed lines and carrying on")
+	decodeCmd.Flags().BoolVar(&firstFrame, "first", false, "decode only the first frame and ignore any tokens after it")
+	decodeCmd.Flags().BoolVar(&qr, "qr", false, "read the QR alphanumeric form written by encode --qr")
+	decodeCmd.Flags().BoolVar(&fixDuplicates, "fix-duplicates", false, "guess at tokens a mobile keyboard doubled (e.g. 汪汪汪 or 嗚汪!!) and warn about each fix; may guess wrong")
+	decodeCmd.Flags().BoolVar(&littleEndian, "little-endian", false, "read the length header as little-endian, to reproduce streams from encoders with the byte order wrong")
+	decodeCmd.Flags().BoolVar(&reveal, "reveal", false, "decode the zero-width characters hidden in cover text by encode --hide-in, ignoring the text itself")
+	decodeCmd.Flags().StringVar(&delimiter, "delimiter", "", "the token delimiter the input was encoded with (encode --delimiter)")
+	decodeCmd.Flags().Lookup("delimiter").NoOptDefVal = defaultDelimiter
+	decodeCmd.Flags().BoolVar(&stream, "stream", true, "with --file and plain tokens, write the payload to stdout as it is decoded instead of reading the whole file first; on a broken stream the payload so far has already been written when decode fails (--stream=false waits for the whole frame)")
+
+	rootCmd.AddCommand(encodeCmd, decodeCmd, newEmitIDMapCmd(&outFile), newDiffCmd(&outFile), newExamplesCmd(&outFile), newStatsCmd(&inFile, &outFile), newStressCmd(&inFile, &outFile))
 	return rootCmd
 }
 
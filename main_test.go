@@ -0,0 +1,1361 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"testing/quick"
+	"unicode/utf8"
+)
+
+func mustEncode(tb testing.TB, text string) string {
+	tb.Helper()
+	speech, err := Encode(text)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return speech
+}
+
+// runCLI runs woofwoof with args, reading stdin, and returns what it wrote to
+// stdout and stderr. Unless the test set WOOFWOOF_CONFIG, no config file is
+// loaded, so ~/.woofwoof.json cannot change the result.
+func runCLI(t *testing.T, stdin string, args ...string) (stdout, stderr string, err error) {
+	t.Helper()
+	if _, ok := os.LookupEnv(envPrefix + "CONFIG"); !ok {
+		t.Setenv(envPrefix+"CONFIG", filepath.Join(t.TempDir(), "none.json"))
+	}
+	var out, errOut bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetArgs(args)
+	cmd.SetIn(strings.NewReader(stdin))
+	cmd.SetOut(&out)
+	cmd.SetErr(&errOut)
+	err = cmd.Execute()
+	return out.String(), errOut.String(), err
+}
+
+func TestPadTokenEveryDecoder(t *testing.T) {
+	const text = "pad me, woof"
+	speech, err := Encode(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := DecodeToIDs(speech)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := append([]byte{0, 0, 0, byte(len(text))}, text...)
+
+	tests := []struct {
+		name  string
+		input string
+		want  []byte
+		dec   func(string) ([]byte, error)
+	}{
+		{"DecodeBytes", speech, []byte(text), DecodeBytes},
+		{"DecodeDetailed", speech, []byte(text), func(s string) ([]byte, error) {
+			res, err := DecodeDetailed(s)
+			return []byte(res.Text), err
+		}},
+		{"DecodePartial", speech, []byte(text), func(s string) ([]byte, error) {
+			text, _, err := DecodePartial(s)
+			return []byte(text), err
+		}},
+		{"DecodeHeaderlessBytes", speech, frame, DecodeHeaderlessBytes},
+		{"decodeFirstBytes", speech, []byte(text), decodeFirstBytes},
+		{"DecodeSpacelessBytes", EncodeSpacelessBytes([]byte(text)), []byte(text), DecodeSpacelessBytes},
+		{"DecodeFromIDs", formatIDs(ids), []byte(text), func(s string) ([]byte, error) {
+			ids, err := parseIDs(s)
+			if err != nil {
+				return nil, err
+			}
+			return DecodeFromIDs(ids)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, in := range []string{tt.input, tt.input + " " + PadToken, tt.input + "\n" + PadToken + "\n"} {
+				got, err := tt.dec(in)
+				if err != nil {
+					t.Fatalf("%q: %v", in, err)
+				}
+				if !bytes.Equal(got, tt.want) {
+					t.Fatalf("%q: got %q, want %q", in, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPadTokenMustEndFrame(t *testing.T) {
+	speech, err := Encode("woof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	extra := speech + " " + codebook[0] + " " + PadToken
+	if _, err := DecodeBytes(extra); err == nil {
+		t.Error("DecodeBytes accepted a pad token after a trailing token")
+	}
+	if _, err := DecodeDetailed(extra); err == nil {
+		t.Error("DecodeDetailed accepted a pad token after a trailing token")
+	}
+	if _, _, err := DecodePartial(extra); err == nil {
+		t.Error("DecodePartial accepted a pad token after a trailing token")
+	}
+	if _, err := DecodeBytes(strings.Repeat(PadToken+" ", 2)); err == nil {
+		t.Error("DecodeBytes accepted two pad tokens")
+	}
+}
+
+func TestPadTokenNotCounted(t *testing.T) {
+	speech := mustEncode(t, "woof woof")
+	n := countTokens(speech)
+	padded := speech + " " + PadToken
+
+	if st, err := tokenStatsOf(padded); err != nil || st.Tokens != n {
+		t.Errorf("tokenStatsOf: %+v, %v; want %d tokens", st, err, n)
+	}
+	if d := Diagnose(padded); d.FirstUnknown != -1 || d.TokenCount != n || !d.Padded || !d.Complete {
+		t.Errorf("Diagnose: %+v", d)
+	}
+	if _, err := DecodeWithLimit(padded, n); err != nil {
+		t.Errorf("DecodeWithLimit at exactly %d tokens: %v", n, err)
+	}
+	if info, err := InspectFrame(padded); err != nil || info.Tokens != n || !info.Complete {
+		t.Errorf("InspectFrame: %+v, %v", info, err)
+	}
+	if res, err := DecodeDetailed(padded); err != nil || res.TokenCount != n {
+		t.Errorf("DecodeDetailed: TokenCount %d, %v; want %d", res.TokenCount, err, n)
+	}
+
+	out, _, err := runCLI(t, "", "encode", "--pad-token", "woof woof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, _, err := runCLI(t, out, "stats"); err != nil || !strings.Contains(out, "tokens: "+strconv.Itoa(n)+"\n") {
+		t.Errorf("stats of padded output: %q, %v", out, err)
+	}
+	if _, _, err := runCLI(t, "", "encode", "--ids", "--pad-token", "woof"); err == nil {
+		t.Error("encode --ids --pad-token dropped the pad token instead of failing")
+	}
+}
+
+func TestDecodeFirstIgnoresTrailingFrames(t *testing.T) {
+	a, b := mustEncode(t, "first"), mustEncode(t, "second")
+	for _, in := range []string{a, a + " " + b, a + " not dog speech at all"} {
+		got, err := DecodeFirst(in)
+		if err != nil || got != "first" {
+			t.Errorf("DecodeFirst(%q) = %q, %v", in, got, err)
+		}
+	}
+}
+
+// BenchmarkDecodeFirst decodes a small frame at the front of megabytes of
+// further tokens, which Decode has to map and DecodeFirst never looks at.
+func BenchmarkDecodeFirst(b *testing.B) {
+	input := mustEncode(b, "a small frame in front") + strings.Repeat(" "+codebook[0], 1<<20)
+	b.Run("DecodeFirst", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := DecodeFirst(input); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Decode", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := Decode(input); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// joinFrame is encodeFrame the way it used to be written: collect the token
+// strings, then join them.
+func joinFrame(total []byte) string {
+	ids := make([]byte, 0, tokensFor(len(total)-4))
+	var bitBuf uint32
+	var bitCount uint8
+	for _, b := range total {
+		bitBuf = bitBuf<<8 | uint32(b)
+		for bitCount += 8; bitCount >= 6; bitCount -= 6 {
+			ids = append(ids, byte(bitBuf>>(bitCount-6))&0x3F)
+		}
+	}
+	if bitCount > 0 {
+		ids = append(ids, byte(bitBuf<<(6-bitCount))&0x3F)
+	}
+	toks := make([]string, 0, len(ids))
+	for _, id := range ids {
+		toks = append(toks, codebook[id])
+	}
+	return strings.Join(toks, " ")
+}
+
+func TestEncodeFrameMatchesJoin(t *testing.T) {
+	for n := range 10 {
+		total := append([]byte{0, 0, 0, byte(n)}, bytes.Repeat([]byte{0xA5, 0x3C, 0xFF}, n)[:n]...)
+		if got, want := encodeFrame(total), joinFrame(total); got != want {
+			t.Fatalf("%d bytes: encodeFrame = %q, want %q", n, got, want)
+		}
+	}
+}
+
+// BenchmarkEncodeFrame isolates token emission: writing tokens straight into
+// a strings.Builder against collecting them and joining.
+func BenchmarkEncodeFrame(b *testing.B) {
+	total := append([]byte{0, 0, 16, 0}, bytes.Repeat([]byte("woof! 汪"), 4096/9+1)[:4096]...)
+	b.Run("builder", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(total)))
+		for b.Loop() {
+			encodeFrame(total)
+		}
+	})
+	b.Run("join", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(total)))
+		for b.Loop() {
+			joinFrame(total)
+		}
+	})
+}
+
+// twoPassDecode is DecodeBytes before decodeTokens fused the passes.
+func twoPassDecode(s string) ([]byte, error) {
+	ids, err := DecodeToIDs(s)
+	if err != nil {
+		return nil, err
+	}
+	return unpackIDs(ids)
+}
+
+func TestDecodeTokensMatchesTwoPass(t *testing.T) {
+	speech := mustEncode(t, "fused decode, same answers")
+	fields := strings.Fields(speech)
+	// Every truncation, plus trailing tokens and a bad token past the frame.
+	inputs := []string{speech + " " + speech, speech + " woof"}
+	for n := 1; n <= len(fields); n++ {
+		inputs = append(inputs, strings.Join(fields[:n], " "))
+	}
+	for _, in := range inputs {
+		got, gotErr := DecodeBytes(in)
+		want, wantErr := twoPassDecode(in)
+		if !bytes.Equal(got, want) || (gotErr == nil) != (wantErr == nil) || (gotErr != nil && gotErr.Error() != wantErr.Error()) {
+			t.Errorf("%q: got %q, %v; want %q, %v", in, got, gotErr, want, wantErr)
+		}
+	}
+}
+
+// BenchmarkDecodeBytes compares the fused decodeTokens with looking up every
+// token into an ids slice and packing it in a second loop.
+func BenchmarkDecodeBytes(b *testing.B) {
+	input := mustEncode(b, strings.Repeat("汪汪 woof! 你好，世界 ", 256))
+	b.Run("fused", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := DecodeBytes(input); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("two-pass", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := twoPassDecode(input); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// utf8Text is a random valid UTF-8 string for testing/quick, mixing ASCII,
+// CJK, emoji and the pad token's runes.
+type utf8Text string
+
+func (utf8Text) Generate(r *rand.Rand, size int) reflect.Value {
+	ranges := [][2]rune{{0, 0x7f}, {0x80, 0x7ff}, {0x4e00, 0x9fff}, {0x1f400, 0x1f4ff}, {'嗷', '嗷'}, {'嗚', '嗚'}}
+	var sb strings.Builder
+	for range r.Intn(size + 1) {
+		rg := ranges[r.Intn(len(ranges))]
+		sb.WriteRune(rg[0] + rune(r.Intn(int(rg[1]-rg[0]+1))))
+	}
+	return reflect.ValueOf(utf8Text(sb.String()))
+}
+
+func TestQuickRoundTrip(t *testing.T) {
+	text := func(s utf8Text) bool {
+		if !utf8.ValidString(string(s)) {
+			return false
+		}
+		speech, err := Encode(string(s))
+		if err != nil {
+			return false
+		}
+		got, err := Decode(speech)
+		return err == nil && got == toNFC(string(s))
+	}
+	if err := quick.Check(text, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+
+	raw := func(payload []byte) bool {
+		got, err := DecodeBytes(EncodeBytes(payload))
+		return err == nil && bytes.Equal(got, payload)
+	}
+	if err := quick.Check(raw, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func FuzzEncodeDecode(f *testing.F) {
+	for _, seed := range []string{"", "\x00", "woof", "汪汪", "\xff\xfe"} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		speech := EncodeBytes(payload)
+		if countTokens(speech) != tokensFor(len(payload)) {
+			t.Fatalf("%d bytes gave %d tokens, want %d", len(payload), countTokens(speech), tokensFor(len(payload)))
+		}
+		got, err := DecodeBytes(speech)
+		if err != nil || !bytes.Equal(got, payload) {
+			t.Fatalf("round trip of %q: %q, %v", payload, got, err)
+		}
+		if got, err := DecodeBytes(speech + " " + PadToken); err != nil || !bytes.Equal(got, payload) {
+			t.Fatalf("round trip of %q with the pad token: %q, %v", payload, got, err)
+		}
+	})
+}
+
+func TestNULPayloads(t *testing.T) {
+	zero := codebook[0] // id 0, what both NUL bytes and padding bits encode to
+	seen := map[string]int{}
+	for _, n := range []int{0, 1, 2, 3, 4, 100} {
+		payload := make([]byte, n)
+		speech := EncodeBytes(payload)
+		if prev, dup := seen[speech]; dup {
+			t.Fatalf("%d and %d NUL bytes encode the same", prev, n)
+		}
+		seen[speech] = n
+
+		// Extra zero tokens look like more NULs, but the header says how many there are.
+		for _, in := range []string{speech, speech + " " + zero, speech + strings.Repeat(" "+zero, 8)} {
+			got, err := DecodeBytes(in)
+			if err != nil || !bytes.Equal(got, payload) {
+				t.Fatalf("%d NUL bytes from %q: got %q, %v", n, in, got, err)
+			}
+		}
+
+		// Headerless decoding has only the token count to go by, so four extra
+		// zero tokens (3 bytes) come back as payload.
+		got, err := DecodeHeaderlessBytes(speech + strings.Repeat(" "+zero, 4))
+		if err != nil || len(got) != 4+n+3 {
+			t.Fatalf("%d NUL bytes headerless: got %d bytes, %v", n, len(got), err)
+		}
+	}
+}
+
+// goldenFrames pins the dog speech of every FormatVersion: the payload and
+// the exact tokens that format writes for it. A version's entries must never
+// change; the current decoder has to read all of them.
+var goldenFrames = map[int][]struct{ payload, speech string }{
+	1: {
+		{"", "汪 汪 汪 汪 汪 汪"},
+		{"a", "汪 汪 汪 汪 汪 嗷！ 汪…"},
+		{"woof", "汪 汪 汪 汪 汪. 汪~. 汪汪! 嗷汪~. 汪汪～ 汪嗚！ 汪汪"},
+		{"汪", "汪 汪 汪 汪 汪 ~汪！ 汪汪~ 汪嗚. 嗷汪~ 嗚汪"},
+		{"\x00\xff", "汪 汪 汪 汪 汪 嗚汪 汪～ ~汪~."},
+		{"Hello, 世界!\n", "汪 汪 汪 汪 汪～ 汪嗚… 嗚汪. 嗚汪! 汪汪～ 汪！ 汪嗚. 嗷汪~. 嗚～ 汪~ 汪～ 嗚汪… 嗷汪！ 嗚. 汪汪～ 嗚汪~. 嗚汪! 汪汪 汪嗚 嗚汪. 汪~ 嗚汪"},
+	},
+}
+
+func TestFormatCompatibility(t *testing.T) {
+	for v := 1; v <= FormatVersion; v++ {
+		frames, ok := goldenFrames[v]
+		if !ok {
+			t.Fatalf("no golden frames for format version %d", v)
+		}
+		for _, g := range frames {
+			got, err := DecodeBytes(g.speech)
+			if err != nil || string(got) != g.payload {
+				t.Errorf("v%d: decoding %q gave %q, %v; want %q", v, g.speech, got, err, g.payload)
+			}
+			if v == FormatVersion {
+				if out := EncodeBytes([]byte(g.payload)); out != g.speech {
+					t.Errorf("v%d: encoding %q gave %q; want %q", v, g.payload, out, g.speech)
+				}
+			}
+		}
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	shared := mustCodec(t, WithCache(8), WithSeparator("|"))
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				text := fmt.Sprintf("%d/%d", g, i%10) // repeats, so the cache gets hits
+				speech, err := shared.Encode(text)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if got, err := shared.Decode(speech); err != nil || got != text {
+					t.Errorf("Codec round trip of %q: %q, %v", text, got, err)
+					return
+				}
+				if got, err := Decode(strings.ReplaceAll(speech, "|", " ")); err != nil || got != text {
+					t.Errorf("Decode of %q: %q, %v", text, got, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestDecodeHeaderTooLarge(t *testing.T) {
+	// A header declaring 0xFFFFFFFF bytes, followed by a few payload tokens.
+	ids := []byte{63, 63, 63, 63, 63, 60, 1, 2, 3, 4}
+	speech, err := EncodeFromIDs(ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err = DecodeBytes(speech)
+	runtime.ReadMemStats(&after)
+	if err == nil || !strings.Contains(err.Error(), "incomplete") {
+		t.Fatalf("DecodeBytes: %v", err)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<16 {
+		t.Errorf("DecodeBytes allocated %d bytes for a %d-byte input", n, len(speech))
+	}
+	if _, err := DecodeFromIDs(ids); err == nil || !strings.Contains(err.Error(), "incomplete") {
+		t.Errorf("DecodeFromIDs: %v", err)
+	}
+	if _, err := DecodeBytes(strings.Repeat(codebook[0]+" ", minHeaderTokens-1)); err == nil || !strings.Contains(err.Error(), "too short") {
+		t.Errorf("short input: %v", err)
+	}
+}
+
+func TestDelimiterSurvivesSpaceCollapse(t *testing.T) {
+	const text = "woof woof, 汪!"
+	for _, flag := range []string{"--delimiter", "--delimiter=::"} {
+		out, _, err := runCLI(t, "", "encode", flag, text)
+		if err != nil {
+			t.Fatalf("%s: %v", flag, err)
+		}
+		// A chat that collapses spaces: every space and the newline are gone.
+		collapsed := strings.Join(strings.Fields(out), "")
+		got, _, err := runCLI(t, collapsed, "decode", flag)
+		if err != nil || got != text+"\n" {
+			t.Errorf("%s: decode %q got %q, %v", flag, collapsed, got, err)
+		}
+		if _, _, err := runCLI(t, collapsed, "decode"); err == nil {
+			t.Errorf("%s: decode without the delimiter accepted %q", flag, collapsed)
+		}
+	}
+}
+
+func TestEncodeVerify(t *testing.T) {
+	for _, flag := range []string{"--verify", "--show-verify"} {
+		out, stderr, err := runCLI(t, "", "encode", flag, "good dog")
+		if err != nil || out != mustEncode(t, "good dog")+"\n" {
+			t.Fatalf("%s: %q, %v", flag, out, err)
+		}
+		if flag == "--show-verify" && stderr != "verified: ok (8 bytes)\n" {
+			t.Errorf("%s: stderr %q", flag, stderr)
+		}
+	}
+
+	verifyDecode = func(s string) ([]byte, error) {
+		b, err := DecodeBytes(s)
+		if len(b) > 0 {
+			b[0] ^= 1
+		}
+		return b, err
+	}
+	t.Cleanup(func() { verifyDecode = DecodeBytes })
+	out, _, err := runCLI(t, "", "encode", "--verify", "good dog")
+	if err == nil || !strings.Contains(err.Error(), "round-trip verification failed") {
+		t.Errorf("--verify with a corrupting decoder: %v", err)
+	}
+	if strings.Contains(out, mustEncode(t, "good dog")) {
+		t.Errorf("--verify printed the output it could not verify: %q", out)
+	}
+	if _, stderr, err := runCLI(t, "", "encode", "--show-verify", "good dog"); err == nil || !strings.HasPrefix(stderr, "verified: failed") {
+		t.Errorf("--show-verify with a corrupting decoder: stderr %q, %v", stderr, err)
+	}
+}
+
+func gzipFile(t *testing.T, path, content string) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func gunzipFile(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("%s is not gzip: %v", path, err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestGzipFiles(t *testing.T) {
+	const text = "compressed woof, 汪汪\n"
+	dir := t.TempDir()
+	in, out, back := filepath.Join(dir, "in.txt.gz"), filepath.Join(dir, "out.woof.gz"), filepath.Join(dir, "back.txt.gz")
+	gzipFile(t, in, text)
+
+	if got, err := readFile(in, nil); err != nil || got != text {
+		t.Fatalf("readFile: %q, %v", got, err)
+	}
+	if _, _, err := runCLI(t, "", "encode", "--file", in, "--output", out); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gunzipFile(t, out), mustEncode(t, text)+"\n"; got != want {
+		t.Fatalf("encode --output .gz: got %q, want %q", got, want)
+	}
+	if _, _, err := runCLI(t, "", "decode", "--file", out, "--output", back); err != nil {
+		t.Fatal(err)
+	}
+	if got := gunzipFile(t, back); got != text+"\n" {
+		t.Fatalf("decode --output .gz: got %q", got)
+	}
+
+	plain := filepath.Join(dir, "plain.gz")
+	if err := os.WriteFile(plain, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readFile(plain, nil); err == nil || !strings.Contains(err.Error(), "plain.gz") {
+		t.Errorf("readFile of a .gz that is not gzip: %v", err)
+	}
+}
+
+func TestDecodeWithLimit(t *testing.T) {
+	speech := mustEncode(t, "woof woof")
+	n := countTokens(speech)
+	tests := []struct {
+		input string
+		limit int
+		ok    bool
+	}{
+		{speech, n, true},
+		{speech, n + 100, true},
+		{speech, n - 1, false},
+		{speech, 0, false},
+		{speech + " " + PadToken, n, true},
+		{speech + " " + PadToken, n - 1, false},
+		{speech + " " + speech, n, false},
+	}
+	for _, tt := range tests {
+		got, err := DecodeWithLimit(tt.input, tt.limit)
+		if tt.ok {
+			if err != nil || got != "woof woof" {
+				t.Errorf("%d tokens, limit %d: %q, %v", countTokens(tt.input), tt.limit, got, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrTooManyTokens) {
+			t.Errorf("%d tokens, limit %d: got %v, want ErrTooManyTokens", countTokens(tt.input), tt.limit, err)
+		}
+	}
+	if _, err := DecodeWithLimit(speech, -1); err == nil || errors.Is(err, ErrTooManyTokens) {
+		t.Errorf("negative limit: %v", err)
+	}
+}
+
+func TestInspectFrame(t *testing.T) {
+	speech := mustEncode(t, "inspect me")
+	fields := strings.Fields(speech)
+	frame := FrameInfo{PayloadLength: 10, FrameTokens: tokensFor(10)}
+	with := func(tokens int) FrameInfo {
+		info := frame
+		info.Tokens, info.Complete = tokens, tokens >= frame.FrameTokens
+		return info
+	}
+	tests := []struct {
+		name  string
+		input string
+		want  FrameInfo
+	}{
+		{"complete", speech, with(len(fields))},
+		{"truncated", strings.Join(fields[:8], " "), with(8)},
+		{"header only", strings.Join(fields[:minHeaderTokens], " "), with(minHeaderTokens)},
+		{"trailing tokens", speech + " " + speech, with(2 * len(fields))},
+		{"trailing garbage", speech + " not tokens", with(len(fields) + 2)},
+	}
+	for _, tt := range tests {
+		if got, err := InspectFrame(tt.input); err != nil || got != tt.want {
+			t.Errorf("%s: got %+v, %v; want %+v", tt.name, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", strings.Join(fields[:3], " "), "bark " + speech} {
+		if _, err := InspectFrame(bad); err == nil {
+			t.Errorf("InspectFrame(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestDecodePartialTruncated(t *testing.T) {
+	const text = "ab汪cd" // 汪 is payload bytes 2-4
+	fields := strings.Fields(mustEncode(t, text))
+	for _, tt := range []struct {
+		tokens    int
+		want      string
+		truncated bool
+	}{
+		{len(fields), text, false},
+		{minHeaderTokens, "", true}, // the header and 2 bits of payload
+		{TokensNeededForBytes(2), "ab", true},
+		{TokensNeededForBytes(3), "ab", true}, // the first byte of 汪 held back
+		{TokensNeededForBytes(4), "ab", true},
+		{TokensNeededForBytes(5), "ab汪", true},
+		{len(fields) - 1, "ab汪c", true},
+	} {
+		got, truncated, err := DecodePartial(strings.Join(fields[:tt.tokens], " "))
+		if err != nil || got != tt.want || truncated != tt.truncated {
+			t.Errorf("%d of %d tokens: %q, truncated %v, %v; want %q, %v", tt.tokens, len(fields), got, truncated, err, tt.want, tt.truncated)
+		}
+	}
+	if _, _, err := DecodePartial(strings.Join(fields[:minHeaderTokens-1], " ")); err == nil {
+		t.Error("DecodePartial accepted a cut inside the header")
+	}
+}
+
+func TestDecodeRange(t *testing.T) {
+	const text = "a汪b🐶c"
+	padded, err := EncodePadded(text, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := map[string]string{
+		"plain":     mustEncode(t, text),
+		"pad token": mustEncode(t, text) + " " + PadToken,
+		"padded":    padded,
+	}
+	boundary := func(i int) bool { return i == len(text) || utf8.RuneStart(text[i]) }
+	for name, speech := range inputs {
+		for start := 0; start <= len(text); start++ {
+			for end := start; end <= len(text); end++ {
+				got, err := DecodeRange(speech, start, end)
+				if start == end || (boundary(start) && boundary(end)) {
+					if err != nil || got != text[start:end] {
+						t.Errorf("%s [%d:%d]: %q, %v; want %q", name, start, end, got, err, text[start:end])
+					}
+				} else if err == nil || !strings.Contains(err.Error(), "splits a UTF-8 sequence") {
+					t.Errorf("%s [%d:%d]: %q, %v; want a split-rune error", name, start, end, got, err)
+				}
+			}
+		}
+		for _, r := range [][2]int{{-1, 1}, {2, 1}, {0, len(text) + 1}} {
+			if _, err := DecodeRange(speech, r[0], r[1]); err == nil || !strings.Contains(err.Error(), "out of bounds") {
+				t.Errorf("%s [%d:%d]: %v", name, r[0], r[1], err)
+			}
+		}
+	}
+	fields := strings.Fields(inputs["plain"])
+	if _, err := DecodeRange(strings.Join(fields[:10], " "), 0, len(text)); err == nil {
+		t.Error("DecodeRange read past the end of a truncated frame")
+	}
+}
+
+func TestDecodeDetailedWarnings(t *testing.T) {
+	speech := mustEncode(t, "warn me")
+	fields := strings.Fields(speech)
+	for _, tt := range []struct {
+		input string
+		want  []string
+	}{
+		{speech, nil},
+		{speech + " " + PadToken, nil},
+		{speech + " " + fields[0], []string{"1 trailing token ignored"}},
+		{speech + " " + fields[0] + " " + fields[1], []string{"2 trailing tokens ignored"}},
+	} {
+		res, err := DecodeDetailed(tt.input)
+		if err != nil || res.Text != "warn me" || !reflect.DeepEqual(res.Warnings, tt.want) {
+			t.Errorf("%q: %q, warnings %q, %v; want %q", tt.input, res.Text, res.Warnings, err, tt.want)
+		}
+	}
+}
+
+func TestClipboard(t *testing.T) {
+	var copied []string
+	fail := false
+	clipboardWriter = func(text string) error {
+		if fail {
+			return errors.New("no display")
+		}
+		copied = append(copied, text)
+		return nil
+	}
+	t.Cleanup(func() { clipboardWriter = copyToClipboard })
+
+	want := mustEncode(t, "copy me")
+	out, stderr, err := runCLI(t, "", "encode", "--clipboard", "copy me")
+	if err != nil || out != want+"\n" || stderr != "" {
+		t.Fatalf("encode --clipboard: %q, stderr %q, %v", out, stderr, err)
+	}
+	if len(copied) != 1 || copied[0] != want {
+		t.Fatalf("copied %q, want %q", copied, want)
+	}
+	if _, _, err := runCLI(t, "", "decode", "--clipboard", want); err != nil || len(copied) != 2 || copied[1] != "copy me" {
+		t.Fatalf("decode --clipboard copied %q, %v", copied, err)
+	}
+
+	fail = true
+	out, stderr, err = runCLI(t, "", "encode", "--clipboard", "copy me")
+	if err != nil || out != want+"\n" {
+		t.Errorf("a clipboard failure failed the command: %q, %v", out, err)
+	}
+	if !strings.HasPrefix(stderr, "warning: could not copy the result to the clipboard: no display") {
+		t.Errorf("stderr %q, want a warning", stderr)
+	}
+}
+
+func TestVerboseLogging(t *testing.T) {
+	for _, tt := range []struct {
+		flags      []string
+		info, dbug bool
+	}{
+		{nil, false, false},
+		{[]string{"-v"}, true, false},
+		{[]string{"-vv"}, true, true},
+		{[]string{"-v", "-v"}, true, true},
+	} {
+		args := append(append([]string{"encode"}, tt.flags...), "log me")
+		out, stderr, err := runCLI(t, "", args...)
+		if err != nil || out != mustEncode(t, "log me")+"\n" {
+			t.Fatalf("%v: %q, %v", tt.flags, out, err)
+		}
+		if tt.info != strings.Contains(stderr, `level=INFO msg=encoded payload_bytes=6`) {
+			t.Errorf("%v: want an info record: %v; stderr %q", tt.flags, tt.info, stderr)
+		}
+		if tt.dbug != strings.Contains(stderr, `level=DEBUG msg="encode options"`) {
+			t.Errorf("%v: want a debug record: %v; stderr %q", tt.flags, tt.dbug, stderr)
+		}
+		if !tt.info && stderr != "" {
+			t.Errorf("%v: wrote %q to stderr", tt.flags, stderr)
+		}
+	}
+
+	var buf bytes.Buffer
+	logger := newLogger(&buf, 0)
+	logger.Info("quiet")
+	logger.Warn("loud")
+	if s := buf.String(); strings.Contains(s, "quiet") || !strings.Contains(s, "level=WARN msg=loud") {
+		t.Errorf("default level: %q", s)
+	}
+}
+
+func TestDecodeLines(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lines.txt")
+	content := mustEncode(t, "one") + "\nbark bark\n" + mustEncode(t, "three") + "\n" + strings.Join(strings.Fields(mustEncode(t, "four"))[:7], " ") + "\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, _, err := runCLI(t, "", "decode", "--lines", "-f", path)
+	if err == nil || err.Error() != "decode error: 2 of 4 lines failed" {
+		t.Errorf("got %v, want 2 of 4 lines failed", err)
+	}
+	got := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(got) < 4 || got[0] != "one" || !strings.HasPrefix(got[1], "[error] line 2: unknown token") || got[2] != "three" || !strings.HasPrefix(got[3], "[error] line 4: ") {
+		t.Errorf("output %q", out)
+	}
+
+	outFile := filepath.Join(dir, "out.txt")
+	if _, _, err := runCLI(t, "", "decode", "--lines", "-f", path, "-o", outFile); err == nil {
+		t.Error("decode --lines -o succeeded with failed lines")
+	}
+	if b, err := os.ReadFile(outFile); err != nil || !strings.HasPrefix(string(b), "one\n[error] line 2:") {
+		t.Errorf("-o file: %q, %v", b, err)
+	}
+
+	good := mustEncode(t, "a") + "\n" + mustEncode(t, "b") + "\n"
+	if out, _, err := runCLI(t, good, "decode", "--lines"); err != nil || out != "a\nb\n" {
+		t.Errorf("all lines good, from stdin: %q, %v", out, err)
+	}
+}
+
+func TestDoubleEncode(t *testing.T) {
+	speech := mustEncode(t, "once")
+	out, stderr, err := runCLI(t, "", "encode", speech)
+	if err != nil || out != mustEncode(t, speech)+"\n" {
+		t.Fatalf("encode of dog speech: %q, %v", out, err)
+	}
+	if !strings.Contains(stderr, "warning: input already looks like dog speech") {
+		t.Errorf("no double-encode warning: %q", stderr)
+	}
+	if _, stderr, _ := runCLI(t, "", "encode", "once"); stderr != "" {
+		t.Errorf("warning for plain text: %q", stderr)
+	}
+	if _, _, err := runCLI(t, "", "encode", "--no-double-encode", speech); err == nil || !strings.Contains(err.Error(), "already dog speech") {
+		t.Errorf("--no-double-encode: %v", err)
+	}
+	if out, _, err := runCLI(t, "", "encode", "--no-double-encode", "once"); err != nil || out != speech+"\n" {
+		t.Errorf("--no-double-encode on plain text: %q, %v", out, err)
+	}
+}
+
+func TestBOM(t *testing.T) {
+	const bom = "\ufeff"
+	out, _, err := runCLI(t, bom+"hi", "encode", "--strip-bom")
+	if err != nil || out != mustEncode(t, "hi")+"\n" {
+		t.Errorf("--strip-bom: %q, %v", out, err)
+	}
+	if out, _, _ := runCLI(t, bom+"hi", "encode"); out != mustEncode(t, bom+"hi")+"\n" {
+		t.Errorf("BOM dropped without --strip-bom: %q", out)
+	}
+	out, _, err = runCLI(t, "", "decode", "--output-bom", mustEncode(t, "hi"))
+	if err != nil || out != bom+"hi\n" {
+		t.Errorf("--output-bom: %q, %v", out, err)
+	}
+}
+
+func TestKeepNewlines(t *testing.T) {
+	const text = "one\ntwo\nthree"
+	out, _, err := runCLI(t, "", "encode", "--keep-newlines", text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(out, "\n"); n != 3 {
+		t.Errorf("--keep-newlines wrote %d lines, want 3: %q", n, out)
+	}
+	if strings.Join(strings.Fields(out), " ") != mustEncode(t, text) {
+		t.Errorf("--keep-newlines changed the tokens: %q", out)
+	}
+	got, _, err := runCLI(t, out, "decode")
+	if err != nil || got != text+"\n" {
+		t.Errorf("decode of --keep-newlines output: %q, %v", got, err)
+	}
+	if out, _, _ := runCLI(t, "", "encode", text); strings.Count(out, "\n") != 1 {
+		t.Errorf("line breaks without --keep-newlines: %q", out)
+	}
+}
+
+func TestEncodeIDs(t *testing.T) {
+	// Header 00 00 00 01 and 'a' (0x61), packed 6 bits at a time.
+	out, _, err := runCLI(t, "", "encode", "--ids", "a")
+	if err != nil || out != "0 0 0 0 0 22 4\n" {
+		t.Errorf("--ids: %q, %v", out, err)
+	}
+	if _, _, err := runCLI(t, "", "encode", "--ids", "--pad-token", "a"); err == nil {
+		t.Error("--ids with --pad-token: no error")
+	}
+}
+
+func TestEncodeFiles(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+	if err := os.WriteFile(a, []byte("first"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("second\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, _, err := runCLI(t, "", "encode", "--files", a, b)
+	if err != nil || out != mustEncode(t, "first\x1esecond\n")+"\n" {
+		t.Fatalf("--files: %q, %v", out, err)
+	}
+	if _, _, err := runCLI(t, "", "encode", "--files"); err == nil {
+		t.Error("--files without paths: no error")
+	}
+	if _, _, err := runCLI(t, "", "encode", "--files", "-f", a, b); err == nil {
+		t.Error("--files with --file: no error")
+	}
+}
+
+func TestEncodePadTo(t *testing.T) {
+	short, _, err := runCLI(t, "", "encode", "--pad-to", "16", "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	long, _, err := runCLI(t, "", "encode", "--pad-to", "16", "sixteen bytes!!!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, want := len(strings.Fields(short)), TokensNeededForBytes(16); n != want || len(strings.Fields(long)) != want {
+		t.Errorf("--pad-to 16: %d and %d tokens, want %d", n, len(strings.Fields(long)), want)
+	}
+	got, stderr, err := runCLI(t, short, "decode")
+	if err != nil || got != "hi\n" {
+		t.Errorf("decode of padded frame: %q, %v (stderr %q)", got, err, stderr)
+	}
+	if _, _, err := runCLI(t, "", "encode", "--pad-to", "2", "too long"); err == nil {
+		t.Error("--pad-to smaller than the input: no error")
+	}
+}
+
+func TestDecodeStrictSpaces(t *testing.T) {
+	speech := mustEncode(t, "rich")
+	pasted := strings.Replace(speech, " ", "\u200b", 1)
+	pasted = strings.Replace(pasted, " ", "\u00a0", 1)
+	out, _, err := runCLI(t, "", "decode", pasted)
+	if err != nil || out != "rich\n" {
+		t.Errorf("decode of rich-text spaces: %q, %v", out, err)
+	}
+	if _, _, err := runCLI(t, "", "decode", "--strict-spaces", pasted); err == nil || !strings.Contains(err.Error(), "non-ASCII separator U+200B") {
+		t.Errorf("--strict-spaces: %v", err)
+	}
+	if out, _, err := runCLI(t, "", "decode", "--strict-spaces", speech); err != nil || out != "rich\n" {
+		t.Errorf("--strict-spaces on ASCII spaces: %q, %v", out, err)
+	}
+}
+
+func TestDecodeTooFewTokens(t *testing.T) {
+	for n := 1; n < minHeaderTokens; n++ {
+		in := strings.TrimSpace(strings.Repeat(codebook[1]+" ", n))
+		_, _, err := runCLI(t, "", "decode", in)
+		if want := fmt.Sprintf("got %d tokens, need at least 6", n); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%d tokens: %v, want %q", n, err, want)
+		}
+	}
+}
+
+func TestRootModeHint(t *testing.T) {
+	speech := mustEncode(t, "meant decode")
+	out, stderr, err := runCLI(t, "", speech)
+	if err != nil || out != mustEncode(t, speech)+"\n" {
+		t.Fatalf("root encode of dog speech: %q, %v", out, err)
+	}
+	if !strings.Contains(stderr, "hint: input looks like dog speech") {
+		t.Errorf("no hint: %q", stderr)
+	}
+	if _, stderr, _ := runCLI(t, "", "--mode", "encode", speech); stderr != "" {
+		t.Errorf("hint with an explicit --mode: %q", stderr)
+	}
+	if _, stderr, _ := runCLI(t, "", "plain text"); stderr != "" {
+		t.Errorf("hint for plain text: %q", stderr)
+	}
+	if out, _, err := runCLI(t, "", "--mode", "decode", speech); err != nil || out != "meant decode\n" {
+		t.Errorf("--mode decode: %q, %v", out, err)
+	}
+}
+
+func TestReadErrorNamesSource(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.txt")
+	_, _, err := runCLI(t, "", "encode", "-f", missing)
+	if want := "read file " + missing + ": "; err == nil || !strings.Contains(err.Error(), want) || strings.Count(err.Error(), missing) != 1 {
+		t.Errorf("missing file: %v, want %q once", err, want)
+	}
+	dir := t.TempDir()
+	if _, _, err := runCLI(t, "", "decode", "-f", dir); err == nil || !strings.Contains(err.Error(), "read file "+dir+": ") {
+		t.Errorf("directory: %v", err)
+	}
+
+	t.Setenv(envPrefix+"CONFIG", filepath.Join(t.TempDir(), "none.json"))
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"encode"})
+	cmd.SetIn(iotest.ErrReader(errors.New("broken pipe")))
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "read stdin: broken pipe") {
+		t.Errorf("stdin: %v", err)
+	}
+}
+
+func TestDecodeForeignInput(t *testing.T) {
+	const hint = "none of the first fields are dog speech"
+	for _, in := range []string{"aGVsbG8gd29vZg==", "68 65 6c 6c 6f", "hello there"} {
+		if _, _, err := runCLI(t, in, "decode"); err == nil || !strings.Contains(err.Error(), hint) {
+			t.Errorf("decode %q: %v, want the %q hint", in, err, hint)
+		}
+	}
+	// A single bad field among tokens is a typo, not another format.
+	fields := strings.Fields(mustEncode(t, "typo"))
+	fields[0] = "bark"
+	_, _, err := runCLI(t, strings.Join(fields, " "), "decode")
+	if err == nil || strings.Contains(err.Error(), hint) || !strings.Contains(err.Error(), `unknown token: "bark"`) {
+		t.Errorf("one bad token: %v", err)
+	}
+}
+
+func TestDecodeHexOnInvalid(t *testing.T) {
+	speech := EncodeBytes([]byte("ok\xff"))
+	if _, _, err := runCLI(t, "", "decode", speech); !errors.Is(err, errInvalidPayload) {
+		t.Errorf("invalid UTF-8 without --hex-on-invalid: %v", err)
+	}
+	out, stderr, err := runCLI(t, "", "decode", "--hex-on-invalid", speech)
+	if err != nil || out != hex.Dump([]byte("ok\xff")) {
+		t.Errorf("--hex-on-invalid: %q, %v", out, err)
+	}
+	if !strings.Contains(stderr, "warning: decoded payload (3 bytes) is not valid UTF-8") {
+		t.Errorf("no warning: %q", stderr)
+	}
+	if out, _, err := runCLI(t, "", "decode", "--hex-on-invalid", mustEncode(t, "ok")); err != nil || out != "ok\n" {
+		t.Errorf("--hex-on-invalid on valid text: %q, %v", out, err)
+	}
+}
+
+func TestDecodeLegacyHeaderless(t *testing.T) {
+	legacy := encodeFrame([]byte("hello"))
+	if _, _, err := runCLI(t, "", "decode", legacy); err == nil {
+		t.Error("headerless stream decoded without --legacy-headerless")
+	}
+	out, _, err := runCLI(t, "", "decode", "--legacy-headerless", legacy)
+	if err != nil || out != "hello\n" {
+		t.Errorf("--legacy-headerless: %q, %v", out, err)
+	}
+	// Without a header, truncation goes unnoticed.
+	fields := strings.Fields(legacy)
+	out, _, err = runCLI(t, "", "decode", "--legacy-headerless", strings.Join(fields[:len(fields)-1], " "))
+	if err != nil || out != "hell\n" {
+		t.Errorf("--legacy-headerless on a truncated stream: %q, %v", out, err)
+	}
+}
+
+func TestCheckCodebook(t *testing.T) {
+	if err := checkCodebook(codebook); err != nil {
+		t.Fatalf("built-in codebook: %v", err)
+	}
+	for _, tok := range []string{"汪 嗚", "汪\u3000嗚", "汪\n", "汪" + runMark + "嗚"} {
+		bad := append(append([]string(nil), codebook[:63]...), tok)
+		err := checkCodebook(bad)
+		if err == nil || !strings.Contains(err.Error(), strconv.Quote(tok)) {
+			t.Errorf("token %q: %v, want an error naming it", tok, err)
+		}
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	want := mustEncode(t, "\x00\x00\x00 same")
+	n := len(strings.Fields(want))
+	for _, in := range []string{
+		want,
+		"  " + strings.ReplaceAll(want, " ", "\u00a0\u200b") + "\n",
+		`"` + want + `"`,
+		"[" + want + "]",
+		want + " " + PadToken,
+		CompactRuns(want),
+		Armor(want, n),
+		Armor(CompactRuns(want), n),
+	} {
+		got, err := Canonicalize(in)
+		if err != nil || got != want {
+			t.Errorf("Canonicalize(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if got, _ := Canonicalize(mustEncode(t, "other")); got == want {
+		t.Error("different payloads canonicalize equally")
+	}
+	if _, err := Canonicalize(Armor(want, n+1)); err == nil {
+		t.Error("armor with a wrong token count: no error")
+	}
+}
+
+func TestDecodeFromIDs(t *testing.T) {
+	out, _, err := runCLI(t, "0 0 0 0 0 22 4", "decode", "--from-ids")
+	if err != nil || out != "a\n" {
+		t.Errorf("--from-ids: %q, %v", out, err)
+	}
+	ids, _, err := runCLI(t, "", "encode", "--ids", "ids and back")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, _, err := runCLI(t, ids, "decode", "--from-ids"); err != nil || out != "ids and back\n" {
+		t.Errorf("--from-ids of encode --ids: %q, %v", out, err)
+	}
+	if _, _, err := runCLI(t, "0 0 0 0 0 64 4", "decode", "--from-ids"); err == nil || !strings.Contains(err.Error(), `id "64" at position 5`) {
+		t.Errorf("--from-ids with id 64: %v", err)
+	}
+	if _, _, err := runCLI(t, ids, "decode"); err == nil {
+		t.Error("ids decoded without --from-ids")
+	}
+}
+
+// writeLog records every Write as its own string.
+type writeLog []string
+
+func (w *writeLog) Write(p []byte) (int, error) {
+	*w = append(*w, string(p))
+	return len(p), nil
+}
+
+func TestTypewriter(t *testing.T) {
+	speech := mustEncode(t, "typed")
+	var w writeLog
+	if err := typewrite(&w, speech, 0); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(strings.Fields(speech)); len(w) != n+1 || strings.Join(w, "") != speech+"\n" || w[n] != "\n" {
+		t.Errorf("typewrite wrote %q, want %d tokens and a newline", w, n)
+	}
+
+	// Not a terminal: the output is written at once, so the delay never runs.
+	out, _, err := runCLI(t, "", "encode", "--typewriter", "--typewriter-delay", "1h", "typed")
+	if err != nil || out != speech+"\n" {
+		t.Errorf("--typewriter off a terminal: %q, %v", out, err)
+	}
+	if _, _, err := runCLI(t, "", "encode", "--typewriter", "--typewriter-delay", "-1s", "typed"); err == nil || !strings.Contains(err.Error(), "--typewriter-delay must not be negative") {
+		t.Errorf("negative --typewriter-delay: %v", err)
+	}
+	if _, stderr, _ := runCLI(t, "", "encode", "--typewriter", "--deterministic", "typed"); !strings.Contains(stderr, "warning: --deterministic overrides --typewriter") {
+		t.Errorf("--typewriter with --deterministic: %q", stderr)
+	}
+}
+
+func TestNegativeLimits(t *testing.T) {
+	if _, err := DecodeWithLimit(mustEncode(t, "x"), -1); err == nil || !strings.Contains(err.Error(), "maxTokens must not be negative, got -1") {
+		t.Errorf("DecodeWithLimit(-1): %v", err)
+	}
+	if _, err := EncodeBytesPadded(nil, -2); err == nil || !strings.Contains(err.Error(), "padded size must not be negative, got -2") {
+		t.Errorf("EncodeBytesPadded(-2): %v", err)
+	}
+	for _, flag := range []string{"--pad-to", "--max-line-length"} {
+		if _, _, err := runCLI(t, "", "encode", flag, "-1", "x"); err == nil || err.Error() != flag+" must not be negative" {
+			t.Errorf("%s -1: %v", flag, err)
+		}
+	}
+}
+
+func TestDecodeLenientWrapping(t *testing.T) {
+	speech := mustEncode(t, "quoted")
+	for _, in := range []string{
+		`"` + speech + `"`,
+		"「" + speech + "」",
+		`("` + speech + `")`,
+		`["` + strings.ReplaceAll(speech, " ", `", "`) + `"]`,
+	} {
+		if _, _, err := runCLI(t, "", "decode", in); err == nil {
+			t.Errorf("decode %q without --lenient: no error", in)
+		}
+		out, _, err := runCLI(t, "", "decode", "--lenient", in)
+		if err != nil || out != "quoted\n" {
+			t.Errorf("--lenient %q: %q, %v", in, out, err)
+		}
+	}
+}
+
+func TestDecodeLenientTrailingNoise(t *testing.T) {
+	speech := mustEncode(t, "woof")
+	// No tone characters such as "!": 汪!! would trim to the valid token 汪!.
+	for _, tail := range []string{"?", "?!", "、", "🐶", "❤️"} {
+		in := speech + tail
+		if _, _, err := runCLI(t, "", "decode", in); err == nil {
+			t.Errorf("decode with %q stuck on without --lenient: no error", tail)
+		}
+		out, _, err := runCLI(t, "", "decode", "--lenient", in)
+		if err != nil || out != "woof\n" {
+			t.Errorf("--lenient with %q stuck on: %q, %v", tail, out, err)
+		}
+	}
+	// Letters are not noise: the last token stays unknown.
+	if _, _, err := runCLI(t, "", "decode", "--lenient", speech+"x"); err == nil {
+		t.Error("--lenient stripped a letter")
+	}
+}
+
+func TestDecodePerRune(t *testing.T) {
+	out, _, err := runCLI(t, "", "decode", "--per-rune", mustEncode(t, "a汪\n"))
+	if want := "U+0061\ta\nU+6C6A\t汪\nU+000A\t'\\n'\n"; err != nil || out != want {
+		t.Errorf("--per-rune: %q, %v; want %q", out, err, want)
+	}
+}
+
+func TestEncodeMaxLineLength(t *testing.T) {
+	speech := mustEncode(t, "long line")
+	n := utf8.RuneCountInString(speech)
+	out, stderr, err := runCLI(t, "", "encode", "--max-line-length", strconv.Itoa(n-1), "long line")
+	if err != nil || out != speech+"\n" {
+		t.Fatalf("--max-line-length: %q, %v", out, err)
+	}
+	if want := fmt.Sprintf("warning: output line 1 is %d characters, over --max-line-length %d", n, n-1); !strings.Contains(stderr, want) {
+		t.Errorf("got %q, want %q", stderr, want)
+	}
+	if _, stderr, _ := runCLI(t, "", "encode", "--max-line-length", strconv.Itoa(n), "long line"); stderr != "" {
+		t.Errorf("warning at exactly the limit: %q", stderr)
+	}
+}
+
+func TestBase64URL(t *testing.T) {
+	raw := []byte{0xfb, 0xff, 0x00, 'w'}
+	// "-_8Adw" is raw in the URL alphabet; padding is optional.
+	for _, in := range []string{"-_8Adw", "-_8Adw==\n"} {
+		out, _, err := runCLI(t, in, "encode", "--base64url")
+		if err != nil || out != EncodeBytes(raw)+"\n" {
+			t.Errorf("encode --base64url %q: %q, %v", in, out, err)
+		}
+	}
+	if _, _, err := runCLI(t, "+/8Adw", "encode", "--base64url"); err == nil || !strings.Contains(err.Error(), "invalid base64url input") {
+		t.Errorf("encode --base64url of standard base64: %v", err)
+	}
+	out, _, err := runCLI(t, "", "decode", "--base64url", EncodeBytes(raw))
+	if err != nil || out != "-_8Adw\n" {
+		t.Errorf("decode --base64url: %q, %v", out, err)
+	}
+}
+
+func TestDecodeBinary(t *testing.T) {
+	raw := "\x00\xff\xfe\n"
+	out, _, err := runCLI(t, "", "decode", "--binary", EncodeBytes([]byte(raw)))
+	if err != nil || out != raw {
+		t.Errorf("--binary: %q, %v; want exactly %q", out, err, raw)
+	}
+	path := filepath.Join(t.TempDir(), "in.woof")
+	if err := os.WriteFile(path, []byte(EncodeBytes([]byte(raw))), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if out, _, err := runCLI(t, "", "decode", "--binary", "-f", path); err != nil || out != raw {
+		t.Errorf("--binary with --file: %q, %v", out, err)
+	}
+	if _, _, err := runCLI(t, "", "decode", "--binary", "--per-rune", mustEncode(t, "x")); err == nil {
+		t.Error("--binary with --per-rune: no error")
+	}
+}
+
+func TestDecodeExpectSHA256(t *testing.T) {
+	speech := mustEncode(t, "checked")
+	sum := sha256.Sum256([]byte("checked"))
+	good := hex.EncodeToString(sum[:])
+	for _, want := range []string{good, strings.ToUpper(good)} {
+		if out, _, err := runCLI(t, "", "decode", "--expect-sha256", want, speech); err != nil || out != "checked\n" {
+			t.Errorf("--expect-sha256 %s: %q, %v", want, out, err)
+		}
+	}
+	bad := strings.Repeat("0", 64)
+	_, _, err := runCLI(t, "", "decode", "--expect-sha256", bad, speech)
+	if want := "payload SHA-256 is " + good + ", expected " + bad; err == nil || !strings.HasSuffix(err.Error(), want) {
+		t.Errorf("wrong --expect-sha256: %v, want %q", err, want)
+	}
+}
+
+func TestTokensNeededForBytes(t *testing.T) {
+	for n, want := range map[int]int{-1: 6, 0: 6, 1: 7, 2: 8, 3: 10, 4: 11, 8: 16, 100: 139} {
+		if got := TokensNeededForBytes(n); got != want {
+			t.Errorf("TokensNeededForBytes(%d) = %d, want %d", n, got, want)
+		}
+	}
+	// Every prefix of that length decodes the bytes, one token fewer does not.
+	const text = "a汪é🐶"
+	fields := strings.Fields(mustEncode(t, text))
+	for i, r := range text {
+		end := i + utf8.RuneLen(r)
+		k := TokensNeededForBytes(end)
+		if got, _, err := DecodePartial(strings.Join(fields[:k], " ")); err != nil || got != text[:end] {
+			t.Errorf("%d tokens: %q, %v; want %q", k, got, err, text[:end])
+		}
+		if got, _, _ := DecodePartial(strings.Join(fields[:k-1], " ")); got == text[:end] {
+			t.Errorf("%d tokens already decode %q", k-1, got)
+		}
+	}
+}
+
+func TestDecodeExtract(t *testing.T) {
+	speech := mustEncode(t, "found it")
+	// A stray token before the message is a shorter run and loses.
+	msg := "hey, " + codebook[9] + " look:\n" + speech + "\ncool right?"
+	if _, _, err := runCLI(t, msg, "decode"); err == nil {
+		t.Error("surrounding text decoded without --extract")
+	}
+	out, _, err := runCLI(t, msg, "decode", "--extract")
+	if err != nil || out != "found it\n" {
+		t.Errorf("--extract: %q, %v", out, err)
+	}
+	if out, _, err := runCLI(t, "x "+CompactRuns(speech)+" "+PadToken+" y", "decode", "--extract"); err != nil || out != "found it\n" {
+		t.Errorf("--extract with runs and the pad token: %q, %v", out, err)
+	}
+	if _, _, err := runCLI(t, "no dogs here", "decode", "--extract"); err == nil || !strings.HasSuffix(err.Error(), "no dog speech found in the input") {
+		t.Errorf("--extract without tokens: %v", err)
+	}
+}
+
+func TestEncodeShowVerify(t *testing.T) {
+	// The byte count is the UTF-8 payload, and other frame forms verify with their own decoder.
+	for _, args := range [][]string{nil, {"--spaceless"}, {"--little-endian"}, {"--armor", "--pretty"}} {
+		_, stderr, err := runCLI(t, "", append(append([]string{"encode", "--show-verify"}, args...), "狗狗")...)
+		if err != nil || stderr != "verified: ok (6 bytes)\n" {
+			t.Errorf("--show-verify %v: stderr %q, %v", args, stderr, err)
+		}
+	}
+
+	// Unlike --verify, the output is written before the failure is reported.
+	verifyDecode = func(string) ([]byte, error) { return nil, errors.New("lost") }
+	t.Cleanup(func() { verifyDecode = DecodeBytes })
+	out, stderr, err := runCLI(t, "", "encode", "--show-verify", "good dog")
+	if !strings.HasPrefix(out, mustEncode(t, "good dog")+"\n") {
+		t.Errorf("--show-verify withheld the output: %q", out)
+	}
+	if err == nil || !strings.HasPrefix(stderr, "verified: failed (") || !strings.Contains(stderr, "lost") {
+		t.Errorf("--show-verify failure: stderr %q, %v", stderr, err)
+	}
+}
+
+func TestDecodeFixDuplicates(t *testing.T) {
+	fields := strings.Fields(mustEncode(t, "stutter"))
+	// A doubled 汪 is the token 汪汪, so only tokens that double into no token can be fixed.
+	canStutter := func(f string) bool {
+		_, ok := lookupToken(f + f)
+		return !ok
+	}
+	i := slices.IndexFunc(fields, func(f string) bool { return strings.HasSuffix(f, "!") && canStutter(f) })
+	j := slices.IndexFunc(fields, func(f string) bool { return !strings.HasSuffix(f, "!") && canStutter(f) })
+	if i < 0 || j < 0 {
+		t.Fatalf("no tokens to stutter in %q", fields)
+	}
+	stuttered := slices.Clone(fields)
+	stuttered[i] = fields[i] + "!" // 嗚汪! typed as 嗚汪!!
+	stuttered[j] = fields[j] + fields[j]
+	in := strings.Join(stuttered, " ")
+	if _, _, err := runCLI(t, "", "decode", in); err == nil {
+		t.Error("stuttered input decoded without --fix-duplicates")
+	}
+	out, stderr, err := runCLI(t, "", "decode", "--fix-duplicates", in)
+	if err != nil || out != "stutter\n" {
+		t.Fatalf("--fix-duplicates: %q, %v", out, err)
+	}
+	for _, want := range []string{
+		fmt.Sprintf("warning: guessed an autocorrect duplicate (token %d %q read as %q)", j, stuttered[j], fields[j]),
+		fmt.Sprintf("warning: guessed an autocorrect duplicate (token %d %q read as %q)", i, stuttered[i], fields[i]),
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("stderr %q lacks %q", stderr, want)
+		}
+	}
+	if _, stderr, _ := runCLI(t, "", "decode", "--fix-duplicates", mustEncode(t, "stutter")); stderr != "" {
+		t.Errorf("--fix-duplicates warned on clean input: %q", stderr)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+// ByteTokenSpan is the range of token indices, First through Last inclusive,
+// whose bits make up one decoded byte. Token indices count the
+// whitespace-separated tokens from 0, header tokens included.
+type ByteTokenSpan struct {
+	First, Last int
+}
+
+// DecodeWithMapping is like Decode, but it also reports, for every byte of the
+// returned text, the tokens that carried it. A byte is 8 bits and a token 6, so
+// each byte spans two tokens, which it shares with its neighbours.
+func DecodeWithMapping(dogSpeech string) (string, []ByteTokenSpan, error) {
+	text, err := Decode(dogSpeech)
+	if err != nil {
+		return "", nil, err
+	}
+	mapping := make([]ByteTokenSpan, len(text))
+	for i := range mapping {
+		bit := (4 + i) * 8 // skip the length header
+		mapping[i] = ByteTokenSpan{First: bit / 6, Last: (bit + 7) / 6}
+	}
+	return text, mapping, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeWithMapping(t *testing.T) {
+	// "a汪" is 61 e6 b1 aa; with its header, 00 00 00 04 61 e6 b1 aa cut into
+	// 6-bit groups:
+	//
+	//	000000 000000 000000 000000 000001 000110 000111 100110 101100 011010 1010(00)
+	ids := []byte{0, 0, 0, 0, 1, 6, 7, 38, 44, 26, 40}
+	tokens := make([]string, len(ids))
+	for i, id := range ids {
+		tokens[i] = codebook[id]
+	}
+	frame := strings.Join(tokens, " ")
+	if got := mustEncode(t, "a汪"); got != frame {
+		t.Fatalf("Encode = %q, want the hand-built frame %q", got, frame)
+	}
+
+	text, mapping, err := DecodeWithMapping(frame)
+	if err != nil || text != "a汪" {
+		t.Fatalf("DecodeWithMapping: %q, %v", text, err)
+	}
+	// 'a' is bits 32-39, tokens 5-6; 汪 is bits 40-63, tokens 6-10.
+	want := []ByteTokenSpan{{5, 6}, {6, 7}, {8, 9}, {9, 10}}
+	if len(mapping) != len(want) {
+		t.Fatalf("got %d spans, want %d", len(mapping), len(want))
+	}
+	for i := range want {
+		if mapping[i] != want[i] {
+			t.Errorf("byte %d: got tokens %d-%d, want %d-%d", i, mapping[i].First, mapping[i].Last, want[i].First, want[i].Last)
+		}
+	}
+	runes := map[string]ByteTokenSpan{"a": {5, 6}, "汪": {6, 10}}
+	for i, r := range text {
+		n := len(string(r))
+		got := ByteTokenSpan{First: mapping[i].First, Last: mapping[i+n-1].Last}
+		if got != runes[string(r)] {
+			t.Errorf("%q: tokens %d-%d, want %d-%d", r, got.First, got.Last, runes[string(r)].First, runes[string(r)].Last)
+		}
+	}
+
+	if _, _, err := DecodeWithMapping(strings.Join(tokens[:8], " ")); err == nil {
+		t.Error("DecodeWithMapping accepted a truncated frame")
+	}
+}
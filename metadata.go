@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+// maxMetadataPairs bounds the pair count a decoder will believe.
+const maxMetadataPairs = 1 << 10
+
+// WithMetadata puts key-value pairs in front of the text inside the frame: a
+// uvarint pair count, then each key and value as a uvarint length and UTF-8
+// bytes, keys sorted. The frame layout changes, so the decoding Codec needs
+// WithMetadata too; pass nil there, since what it reads comes from the frame.
+func WithMetadata(meta map[string]string) Option {
+	return func(c *Codec) error {
+		if len(meta) > maxMetadataPairs {
+			return fmt.Errorf("metadata has %d pairs, more than %d", len(meta), maxMetadataPairs)
+		}
+		cp := make(map[string]string, len(meta))
+		for k, v := range meta {
+			if k == "" {
+				return errors.New("metadata keys must not be empty")
+			}
+			if !utf8.ValidString(k) || !utf8.ValidString(v) {
+				return fmt.Errorf("metadata pair %q is not valid UTF-8", k)
+			}
+			cp[k] = v
+		}
+		c.meta, c.hasMeta = cp, true
+		return nil
+	}
+}
+
+// LanguageKey is the metadata key WithLanguage stores its tag under.
+const LanguageKey = "lang"
+
+// WithLanguage records a BCP 47 language tag such as "ja" or "zh-Hant" in the
+// frame metadata, so a reader can pick the right regional glyphs for the
+// tokens; DecodeDetailed returns it as Result.Language. The tag is stored in
+// canonical form and wins over a "lang" key given to WithMetadata. Like
+// WithMetadata it changes the frame layout, so the decoding Codec needs
+// WithMetadata(nil) or WithLanguage as well.
+func WithLanguage(tag string) Option {
+	return func(c *Codec) error {
+		t, err := canonicalTag(tag)
+		if err != nil {
+			return fmt.Errorf("language tag %q: %w", tag, err)
+		}
+		c.lang, c.hasMeta = t, true
+		return nil
+	}
+}
+
+// TimeKey and LabelKey are the metadata keys WithTimestamp stores under.
+const (
+	TimeKey  = "time"
+	LabelKey = "label"
+)
+
+// maxLabelBytes bounds the label WithTimestamp accepts.
+const maxLabelBytes = 64
+
+// timeNow is the clock WithTimestamp reads; tests replace it.
+var timeNow = time.Now
+
+// WithTimestamp records when each frame was encoded, in UTC as RFC 3339 with
+// nanoseconds, and label unless it is empty, in the frame metadata;
+// DecodeDetailed returns them as Result.Time and Result.Label. Both win over
+// the same keys given to WithMetadata, and with WithDeterministic the time
+// written is the zero time. Like WithMetadata it changes the frame layout, so
+// the decoding Codec needs WithMetadata(nil) or WithTimestamp as well.
+func WithTimestamp(label string) Option {
+	return func(c *Codec) error {
+		if len(label) > maxLabelBytes {
+			return fmt.Errorf("label is %d bytes, more than %d", len(label), maxLabelBytes)
+		}
+		if !utf8.ValidString(label) {
+			return errors.New("label is not valid UTF-8")
+		}
+		c.stamp, c.label, c.hasMeta = true, label, true
+		return nil
+	}
+}
+
+// appendMetadata writes meta to dst in the WithMetadata layout.
+func appendMetadata(dst []byte, meta map[string]string) []byte {
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	dst = binary.AppendUvarint(dst, uint64(len(keys)))
+	for _, k := range keys {
+		dst = binary.AppendUvarint(dst, uint64(len(k)))
+		dst = append(dst, k...)
+		dst = binary.AppendUvarint(dst, uint64(len(meta[k])))
+		dst = append(dst, meta[k]...)
+	}
+	return dst
+}
+
+// cutMetadata reads the pairs at the front of payload and returns them with the rest.
+func cutMetadata(payload []byte) (map[string]string, []byte, error) {
+	next := func() (string, error) {
+		n, w := binary.Uvarint(payload)
+		if w <= 0 || n > uint64(len(payload)-w) {
+			return "", errors.New("metadata truncated or malformed")
+		}
+		s := string(payload[w : w+int(n)])
+		payload = payload[w+int(n):]
+		if !utf8.ValidString(s) {
+			return "", errors.New("metadata is not valid UTF-8")
+		}
+		return s, nil
+	}
+
+	count, w := binary.Uvarint(payload)
+	if w <= 0 || count > maxMetadataPairs {
+		return nil, nil, errors.New("metadata pair count missing or too large")
+	}
+	payload = payload[w:]
+	meta := make(map[string]string, count)
+	for range count {
+		k, err := next()
+		if err != nil {
+			return nil, nil, err
+		}
+		v, err := next()
+		if err != nil {
+			return nil, nil, err
+		}
+		meta[k] = v
+	}
+	return meta, payload, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestMetadataRoundTrip(t *testing.T) {
+	for _, meta := range []map[string]string{{}, {"a": ""}, {"author": "rex", "mood": "汪", "z": "last"}} {
+		speech, err := mustCodec(t, WithMetadata(meta)).Encode("text after metadata")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := mustCodec(t, WithMetadata(nil)).DecodeDetailed(speech)
+		if err != nil {
+			t.Fatalf("%v: %v", meta, err)
+		}
+		if res.Text != "text after metadata" || !maps.Equal(res.Metadata, meta) {
+			t.Fatalf("%v: got %q with %v", meta, res.Text, res.Metadata)
+		}
+	}
+}
+
+func TestMetadataCopiesInput(t *testing.T) {
+	meta := map[string]string{"k": "before"}
+	c := mustCodec(t, WithMetadata(meta))
+	meta["k"] = "after"
+	speech, err := c.Encode("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.DecodeDetailed(speech)
+	if err != nil || res.Metadata["k"] != "before" {
+		t.Fatalf("got %v, %v", res.Metadata, err)
+	}
+}
+
+func TestMetadataRejects(t *testing.T) {
+	if _, err := NewCodec(WithMetadata(map[string]string{"": "v"})); err == nil {
+		t.Error("accepted an empty key")
+	}
+	if _, err := NewCodec(WithMetadata(map[string]string{"k": "\xff"})); err == nil {
+		t.Error("accepted invalid UTF-8")
+	}
+	for _, payload := range [][]byte{{}, {0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, {1, 5, 'a'}} {
+		if _, _, err := cutMetadata(payload); err == nil {
+			t.Errorf("cutMetadata accepted %q", payload)
+		}
+	}
+}
+
+func TestTimestampRoundTrip(t *testing.T) {
+	at := time.Date(2026, 10, 14, 5, 6, 7, 890, time.FixedZone("UTC+8", 8*3600))
+	defer func(orig func() time.Time) { timeNow = orig }(timeNow)
+	timeNow = func() time.Time { return at }
+
+	for _, label := range []string{"", "snippet #1"} {
+		speech, err := mustCodec(t, WithTimestamp(label)).Encode("woof with a time")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, dec := range []*Codec{mustCodec(t, WithTimestamp("")), mustCodec(t, WithMetadata(nil))} {
+			res, err := dec.DecodeDetailed(speech)
+			if err != nil {
+				t.Fatalf("label %q: %v", label, err)
+			}
+			if res.Text != "woof with a time" || !res.Time.Equal(at) || res.Label != label {
+				t.Fatalf("label %q: got text %q, time %v, label %q", label, res.Text, res.Time, res.Label)
+			}
+			if _, ok := res.Metadata[LabelKey]; ok != (label != "") {
+				t.Fatalf("label %q: metadata %v", label, res.Metadata)
+			}
+		}
+	}
+}
+
+func TestTimestampDeterministic(t *testing.T) {
+	c := mustCodec(t, WithTimestamp("fixed"), WithDeterministic())
+	a, err := c.Encode("same")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.Encode("same")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Fatal("WithDeterministic output differs between calls")
+	}
+	res, err := c.DecodeDetailed(a)
+	if err != nil || !res.Time.IsZero() || res.Label != "fixed" {
+		t.Fatalf("got time %v, label %q, %v", res.Time, res.Label, err)
+	}
+}
+
+func TestTimestampRejectsLongLabel(t *testing.T) {
+	if _, err := NewCodec(WithTimestamp(string(make([]byte, maxLabelBytes+1)))); err == nil {
+		t.Error("WithTimestamp accepted an over-long label")
+	}
+}
+
+func TestLanguageRoundTrip(t *testing.T) {
+	c := mustCodec(t, WithMetadata(map[string]string{LanguageKey: "en", "x": "y"}), WithLanguage("ja"))
+	speech, err := c.Encode("こんにちは")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := mustCodec(t, WithMetadata(nil)).DecodeDetailed(speech)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Text != "こんにちは" || res.Language != "ja" || res.Metadata["x"] != "y" {
+		t.Fatalf("got %q, language %q, metadata %v", res.Text, res.Language, res.Metadata)
+	}
+	for _, tag := range []string{"", "not a tag", "toolongsubtag-x"} {
+		if _, err := NewCodec(WithLanguage(tag)); err == nil {
+			t.Errorf("WithLanguage(%q) accepted", tag)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+//go:build !woof_nonorm
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// inertRunes are the non-ASCII runes of the codebook and the pad token. Each is
+// NFC on its own and has a normalization boundary on both sides, so a string of
+// only these and ASCII is already NFC and nothing in it can combine.
+var inertRunes []rune
+
+func init() {
+	seen := map[rune]bool{}
+	for _, s := range append(append([]string(nil), codebook...), PadToken) {
+		for _, r := range s {
+			if r < utf8.RuneSelf || seen[r] {
+				continue
+			}
+			p := norm.NFC.PropertiesString(string(r))
+			if !p.BoundaryBefore() || !p.BoundaryAfter() || !norm.NFC.IsNormalString(string(r)) {
+				continue
+			}
+			seen[r] = true
+			inertRunes = append(inertRunes, r)
+		}
+	}
+}
+
+// toNFC is norm.NFC.String with a fast path for the common inputs: plain ASCII
+// and dog speech that is already in its canonical form are returned as is
+// without running the normalizer.
+func toNFC(s string) string {
+	for i := 0; i < len(s); {
+		if s[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		r, n := utf8.DecodeRuneInString(s[i:])
+		if !isInert(r) {
+			return norm.NFC.String(s)
+		}
+		i += n
+	}
+	return s
+}
+
+func isInert(r rune) bool {
+	for _, x := range inertRunes {
+		if x == r {
+			return true
+		}
+	}
+	return false
+}
+
+// isNFC reports whether s is already in NFC.
+func isNFC(s string) bool {
+	return norm.NFC.IsNormalString(s)
+}
+
+// normalizer returns the Unicode normalization named by --norm. Decoding
+// always returns the payload bytes as encoded, so a round trip reproduces the
+// input exactly only when it was already in that form (or the form is "none").
+func normalizer(name string) (func(string) string, error) {
+	switch strings.ToUpper(name) {
+	case "NFC":
+		return toNFC, nil
+	case "NFD":
+		return norm.NFD.String, nil
+	case "NFKC":
+		return norm.NFKC.String, nil
+	case "NFKD":
+		return norm.NFKD.String, nil
+	case "NONE":
+		return func(s string) string { return s }, nil
+	default:
+		return nil, fmt.Errorf("unknown normalization form %q (want NFC, NFD, NFKC, NFKD or none)", name)
+	}
+}
@@ -0,0 +1,35 @@
+//go:build woof_nonorm
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// This build leaves out golang.org/x/text: no text is ever normalized, so the
+// bytes are encoded exactly as given. Dog speech pasted through something that
+// decomposes it (NFD) no longer decodes, a dictionary phrase only matches its
+// exact bytes, and a text encoded here can differ from the same text encoded
+// by a normal build if it was not NFC to begin with.
+
+// toNFC returns s unchanged.
+func toNFC(s string) string {
+	return s
+}
+
+// isNFC always reports true, since nothing is normalized.
+func isNFC(string) bool {
+	return true
+}
+
+// normalizer returns the Unicode normalization named by --norm. Only NFC (the
+// default) and none are accepted, and both leave the input as it is.
+func normalizer(name string) (func(string) string, error) {
+	switch strings.ToUpper(name) {
+	case "NFC", "NONE":
+		return func(s string) string { return s }, nil
+	default:
+		return nil, fmt.Errorf("normalization form %q is not available in a woof_nonorm build (want NFC or none, which both skip normalization)", name)
+	}
+}
@@ -0,0 +1,33 @@
+//go:build woof_nonorm
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNoNormalization(t *testing.T) {
+	for _, form := range []string{"NFD", "NFKC", "nfkd"} {
+		if _, err := normalizer(form); err == nil || !strings.Contains(err.Error(), "woof_nonorm") {
+			t.Errorf("normalizer(%q): %v", form, err)
+		}
+	}
+	for _, form := range []string{"NFC", "none"} {
+		f, err := normalizer(form)
+		if err != nil || f("e\u0301") != "e\u0301" {
+			t.Errorf("normalizer(%q) changed its input or failed: %v", form, err)
+		}
+	}
+
+	// Not NFC, so a normal build would compose it; this one keeps the bytes.
+	for _, text := range []string{"plain", "cafe\u0301", "A\u030a 汪"} {
+		got, err := Decode(mustEncode(t, text))
+		if err != nil || got != text {
+			t.Errorf("round trip of %q: %q, %v", text, got, err)
+		}
+	}
+	if _, _, err := runCLI(t, "", "encode", "--norm", "NFD", "woof"); err == nil {
+		t.Error("encode --norm NFD succeeded without a normalizer")
+	}
+}
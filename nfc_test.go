@@ -0,0 +1,69 @@
+//go:build !woof_nonorm
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestToNFCMatchesNorm(t *testing.T) {
+	nfc, err := normalizer("NFC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"", "plain ascii", "你好，世界", "é", "Å", mustEncode(t, "café")} {
+		if got, want := toNFC(s), norm.NFC.String(s); got != want {
+			t.Errorf("toNFC(%q) = %q, want %q", s, got, want)
+		}
+		if got, want := nfc(s), norm.NFC.String(s); got != want {
+			t.Errorf("normalizer(\"NFC\")(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+// BenchmarkNFC compares toNFC with always running the normalizer, on inputs
+// that are already NFC: ASCII text, CJK text and dog speech.
+func BenchmarkNFC(b *testing.B) {
+	inputs := []struct{ name, s string }{
+		{"ascii", strings.Repeat("the quick brown fox ", 64)},
+		{"cjk", strings.Repeat("敏捷的棕色狐狸", 64)},
+		{"speech", mustEncode(b, strings.Repeat("woof ", 64))},
+	}
+	for _, in := range inputs {
+		b.Run(in.name+"/toNFC", func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				toNFC(in.s)
+			}
+		})
+		b.Run(in.name+"/norm", func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				norm.NFC.String(in.s)
+			}
+		})
+	}
+}
+
+func TestEncodeNorm(t *testing.T) {
+	const text = "café ﬁ"
+	for _, form := range []string{"NFC", "nfd", "NFKC", "NFKD", "none"} {
+		normalize, err := normalizer(form)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, _, err := runCLI(t, "", "encode", "--norm", form, text)
+		if want := EncodeBytes([]byte(normalize(text))) + "\n"; err != nil || out != want {
+			t.Errorf("--norm %s: %q, %v; want %q", form, out, err, want)
+		}
+	}
+	if out, _, _ := runCLI(t, "", "encode", "--norm", "nfd", text); out == EncodeBytes([]byte(text))+"\n" {
+		t.Error("--norm nfd left the input unchanged")
+	}
+	if _, _, err := runCLI(t, "", "encode", "--norm", "NFX", text); err == nil || !strings.Contains(err.Error(), "unknown normalization form") {
+		t.Errorf("--norm NFX: %v", err)
+	}
+}
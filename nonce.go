@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+// WithNonce starts every frame with a length byte and n random bytes, so
+// encoding the same text twice gives different output. Decoding skips them,
+// reading the length from the frame, so the decoding Codec needs WithNonce
+// too, with any n.
+func WithNonce(n int) Option {
+	return func(c *Codec) error {
+		if n < 1 || n > 255 {
+			return errors.New("nonce length must be between 1 and 255")
+		}
+		c.nonce = n
+		return nil
+	}
+}
+
+// WithDeterministic makes the Codec's output a pure function of its input, for
+// tests and content-addressed storage. It overrides WithNonce whichever comes
+// first: the nonce bytes are written as zeros, so the frame layout stays the
+// same and any WithNonce Codec still decodes it. WithTimestamp then records
+// the zero time. Without either a Codec is already deterministic.
+func WithDeterministic() Option {
+	return func(c *Codec) error {
+		c.deterministic = true
+		return nil
+	}
+}
+
+// appendNonce writes the nonce length and n random bytes to dst, or n zero
+// bytes when zero is set.
+func appendNonce(dst []byte, n int, zero bool) []byte {
+	dst = append(dst, byte(n))
+	nonce := make([]byte, n)
+	if !zero {
+		rand.Read(nonce)
+	}
+	return append(dst, nonce...)
+}
+
+// cutNonce drops the nonce from the front of payload.
+func cutNonce(payload []byte) ([]byte, error) {
+	if len(payload) == 0 || len(payload) < 1+int(payload[0]) {
+		return nil, errors.New("payload too short for its nonce")
+	}
+	return payload[1+int(payload[0]):], nil
+}
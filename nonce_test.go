@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestNonceRoundTrip(t *testing.T) {
+	c := mustCodec(t, WithNonce(8))
+	a, err := c.Encode("same text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.Encode("same text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Error("two encodes with a nonce gave the same output")
+	}
+	// Any nonce length decodes, since the frame carries it.
+	dec := mustCodec(t, WithNonce(1))
+	for _, s := range []string{a, b} {
+		if got, err := dec.Decode(s); err != nil || got != "same text" {
+			t.Fatalf("Decode = %q, %v", got, err)
+		}
+	}
+	for _, n := range []int{0, 256} {
+		if _, err := NewCodec(WithNonce(n)); err == nil {
+			t.Errorf("WithNonce(%d) accepted", n)
+		}
+	}
+	if _, err := cutNonce([]byte{5, 1, 2}); err == nil {
+		t.Error("cutNonce accepted a payload shorter than its nonce")
+	}
+}
+
+func TestDeterministicNonce(t *testing.T) {
+	for _, opts := range [][]Option{{WithNonce(8), WithDeterministic()}, {WithDeterministic(), WithNonce(8)}} {
+		c := mustCodec(t, opts...)
+		a, err := c.Encode("reproducible")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := c.Encode("reproducible")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a != b {
+			t.Fatal("WithDeterministic output differs between calls")
+		}
+		if got, err := mustCodec(t, WithNonce(8)).Decode(a); err != nil || got != "reproducible" {
+			t.Fatalf("a plain WithNonce Codec decoded %q, %v", got, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SplitFrame cuts dog speech into parts of at most maxTokensPerPart tokens, for
+// media with a per-message size limit. Each part starts with an "#i/n" field
+// (1-based) so JoinParts can put them back together in any order. A
+// maxTokensPerPart below 1 means no limit, giving a single part.
+func SplitFrame(dogSpeech string, maxTokensPerPart int) []string {
+	tokens := strings.Fields(dogSpeech)
+	if maxTokensPerPart < 1 || maxTokensPerPart > len(tokens) {
+		maxTokensPerPart = max(len(tokens), 1)
+	}
+	total := max((len(tokens)+maxTokensPerPart-1)/maxTokensPerPart, 1)
+
+	parts := make([]string, 0, total)
+	for i := 0; i < total; i++ {
+		chunk := tokens[min(i*maxTokensPerPart, len(tokens)):min((i+1)*maxTokensPerPart, len(tokens))]
+		header := fmt.Sprintf("#%d/%d", i+1, total)
+		parts = append(parts, strings.Join(append([]string{header}, chunk...), " "))
+	}
+	return parts
+}
+
+// JoinParts reassembles the output of SplitFrame, whatever order the parts
+// arrive in. It fails if a part is missing, duplicated or from a split with a
+// different total.
+func JoinParts(parts []string) (string, error) {
+	if len(parts) == 0 {
+		return "", fmt.Errorf("no parts to join")
+	}
+
+	bodies := make(map[int]string, len(parts))
+	total := 0
+	for _, p := range parts {
+		header, body, _ := strings.Cut(strings.TrimSpace(p), " ")
+		var i, n int
+		if _, err := fmt.Sscanf(header, "#%d/%d", &i, &n); err != nil || n < 1 || i < 1 || i > n {
+			return "", fmt.Errorf("part has no valid #i/n header: %q", header)
+		}
+		if total == 0 {
+			total = n
+		} else if n != total {
+			return "", fmt.Errorf("part %q belongs to a split of %d parts, others say %d", header, n, total)
+		}
+		if _, dup := bodies[i]; dup {
+			return "", fmt.Errorf("part %d/%d appears twice", i, n)
+		}
+		bodies[i] = body
+	}
+	if len(bodies) != total {
+		var missing []string
+		for i := 1; i <= total; i++ {
+			if _, ok := bodies[i]; !ok {
+				missing = append(missing, fmt.Sprint(i))
+			}
+		}
+		return "", fmt.Errorf("missing parts %s of %d", strings.Join(missing, ", "), total)
+	}
+
+	ordered := make([]string, 0, total)
+	for i := 1; i <= total; i++ {
+		if b := strings.TrimSpace(bodies[i]); b != "" {
+			ordered = append(ordered, b)
+		}
+	}
+	return strings.Join(ordered, " "), nil
+}
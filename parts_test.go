@@ -0,0 +1,44 @@
+package main
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+func TestSplitJoinShuffled(t *testing.T) {
+	speech := mustEncode(t, "a frame long enough to be cut into several parts")
+	rng := rand.New(rand.NewPCG(1, 2))
+	for _, size := range []int{0, 1, 5, 17, countTokens(speech), countTokens(speech) + 10} {
+		parts := SplitFrame(speech, size)
+		if size >= 1 && size < countTokens(speech) && len(parts) != (countTokens(speech)+size-1)/size {
+			t.Fatalf("size %d: %d parts", size, len(parts))
+		}
+		rng.Shuffle(len(parts), func(i, j int) { parts[i], parts[j] = parts[j], parts[i] })
+		joined, err := JoinParts(parts)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if joined != speech {
+			t.Fatalf("size %d: joined %q", size, joined)
+		}
+	}
+}
+
+func TestJoinPartsRejects(t *testing.T) {
+	parts := SplitFrame(mustEncode(t, "three parts, please"), 10)
+	if len(parts) < 3 {
+		t.Fatalf("only %d parts", len(parts))
+	}
+	other := SplitFrame(mustEncode(t, "x"), 2)
+	for name, in := range map[string][]string{
+		"none":      nil,
+		"missing":   parts[1:],
+		"duplicate": append([]string{parts[0]}, parts...),
+		"mixed":     append(parts[:len(parts)-1:len(parts)-1], other[0]),
+		"no header": {"汪 汪"},
+	} {
+		if _, err := JoinParts(in); err == nil {
+			t.Errorf("%s: JoinParts succeeded", name)
+		}
+	}
+}
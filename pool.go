@@ -0,0 +1,36 @@
+package main
+
+import "sync"
+
+// scratchPool recycles the framed payload buffer that the encoders only need
+// while they run. Nothing returned to a caller ever aliases it, and oversized
+// buffers are dropped instead of being kept alive by the pool. Decoding does
+// not use it: decodeTokens packs each id straight into the payload it returns,
+// so there is no scratch buffer to recycle.
+var scratchPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 512)
+		return &b
+	},
+}
+
+const maxPooledScratch = 64 << 10
+
+// getScratch returns a pooled buffer of length n; its contents are garbage.
+func getScratch(n int) *[]byte {
+	p := scratchPool.Get().(*[]byte)
+	if cap(*p) < n {
+		*p = make([]byte, 0, n)
+	}
+	*p = (*p)[:n]
+	return p
+}
+
+// putScratch hands a buffer from getScratch back to the pool.
+func putScratch(p *[]byte) {
+	if cap(*p) > maxPooledScratch {
+		return
+	}
+	*p = (*p)[:0]
+	scratchPool.Put(p)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestEncodeConcurrentScratch(t *testing.T) {
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 200 {
+				text := fmt.Sprintf("goroutine %d message %d", g, i)
+				speech, err := Encode(text)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if got, err := Decode(speech); err != nil || got != text {
+					t.Errorf("round trip of %q: %q, %v", text, got, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// BenchmarkEncodeParallel encodes small messages from many goroutines, with
+// the framed buffer from scratchPool and freshly allocated.
+func BenchmarkEncodeParallel(b *testing.B) {
+	payload := []byte("a small message, as a server would encode")
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				EncodeBytes(payload)
+			}
+		})
+	})
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				total := make([]byte, 4+len(payload))
+				binary.BigEndian.PutUint32(total, uint32(len(payload)))
+				copy(total[4:], payload)
+				encodeFrame(total)
+			}
+		})
+	})
+}
+
+// BenchmarkDecodeParallel decodes small messages from many goroutines. Decode
+// takes nothing from scratchPool; its allocations are the payload and the
+// result string.
+func BenchmarkDecodeParallel(b *testing.B) {
+	speech := mustEncode(b, "a small message, as a server would decode")
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := Decode(speech); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
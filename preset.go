@@ -0,0 +1,99 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// presetFiles holds the bundled presets: one file per preset, 64 tokens, one
+// per line, in id order.
+//
+//go:embed presets/*.txt
+var presetFiles embed.FS
+
+// presets maps a --preset name to its renderer, filled once in init.
+var presets = map[string]PresetRenderer{}
+
+// PresetRenderer swaps every codebook token for the token with the same id in
+// another vocabulary, so a preset changes how dog speech sounds but not the format.
+type PresetRenderer struct {
+	tokens  [64]string
+	reverse map[string]string // preset token -> codebook token
+}
+
+func init() {
+	entries, err := presetFiles.ReadDir("presets")
+	if err != nil {
+		panic("read embedded presets: " + err.Error())
+	}
+	for _, e := range entries {
+		data, err := presetFiles.ReadFile(path.Join("presets", e.Name()))
+		if err != nil {
+			panic("read embedded preset: " + err.Error())
+		}
+		name := strings.TrimSuffix(e.Name(), ".txt")
+		p, err := parsePreset(string(data))
+		if err != nil {
+			panic(fmt.Sprintf("preset %s: %v", name, err))
+		}
+		presets[name] = p
+	}
+}
+
+// parsePreset reads one token per line and checks the set is a usable codebook.
+func parsePreset(data string) (PresetRenderer, error) {
+	lines := strings.Split(strings.TrimRight(data, "\n"), "\n")
+	if len(lines) != len(codebook) {
+		return PresetRenderer{}, fmt.Errorf("has %d tokens, want %d", len(lines), len(codebook))
+	}
+	p := PresetRenderer{reverse: make(map[string]string, len(lines))}
+	for id, tok := range lines {
+		switch {
+		case tok == "" || strings.IndexFunc(tok, unicode.IsSpace) >= 0:
+			return PresetRenderer{}, fmt.Errorf("token %d is empty or contains whitespace", id)
+		case tok == PadToken || strings.Contains(tok, runMark) || strings.ContainsAny(tok, prettyComma+prettyPeriod):
+			return PresetRenderer{}, fmt.Errorf("token %q clashes with the pad token, run mark or --pretty punctuation", tok)
+		}
+		if _, dup := p.reverse[tok]; dup {
+			return PresetRenderer{}, fmt.Errorf("duplicate token %q", tok)
+		}
+		p.tokens[id] = tok
+		p.reverse[tok] = codebook[id]
+	}
+	return p, nil
+}
+
+func (p PresetRenderer) Render(token string) string {
+	if id, ok := reverseTable[token]; ok {
+		return p.tokens[id]
+	}
+	return token // e.g. the pad token
+}
+
+func (p PresetRenderer) Normalize(dogSpeech string) string {
+	fields := strings.Fields(dogSpeech)
+	for i, f := range fields {
+		if tok, ok := p.reverse[f]; ok {
+			fields[i] = tok
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// lookupPreset returns the renderer for a --preset name.
+func lookupPreset(name string) (Renderer, error) {
+	p, ok := presets[name]
+	if !ok {
+		names := make([]string, 0, len(presets))
+		for n := range presets {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown preset %q (want one of %s)", name, strings.Join(names, ", "))
+	}
+	return p, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPresetsRoundTrip(t *testing.T) {
+	for _, name := range []string{"angry", "cat", "puppy"} {
+		p, ok := presets[name]
+		if !ok {
+			t.Errorf("preset %s not loaded", name)
+			continue
+		}
+		out, _, err := runCLI(t, "", "encode", "--preset", name, "preset 汪")
+		if err != nil {
+			t.Fatalf("encode --preset %s: %v", name, err)
+		}
+		for _, f := range strings.Fields(out) {
+			if _, ok := p.reverse[f]; !ok {
+				t.Errorf("--preset %s wrote %q, not one of its tokens", name, f)
+			}
+		}
+		if got, _, err := runCLI(t, "", "decode", "--preset", name, out); err != nil || got != "preset 汪\n" {
+			t.Errorf("decode --preset %s: %q, %v", name, got, err)
+		}
+	}
+	if _, _, err := runCLI(t, "", "encode", "--preset", "cow", "x"); err == nil || !strings.Contains(err.Error(), `unknown preset "cow" (want one of angry, cat, puppy)`) {
+		t.Errorf("--preset cow: %v", err)
+	}
+}
+
+func TestParsePresetRejects(t *testing.T) {
+	tokens := make([]string, len(codebook))
+	for i := range tokens {
+		tokens[i] = "t" + codebook[i]
+	}
+	if _, err := parsePreset(strings.Join(tokens, "\n") + "\n"); err != nil {
+		t.Fatalf("valid preset: %v", err)
+	}
+	for name, bad := range map[string][]string{
+		"short":       tokens[:63],
+		"duplicate":   append(append([]string(nil), tokens[:63]...), tokens[0]),
+		"space":       append(append([]string(nil), tokens[:63]...), "a b"),
+		"pad token":   append(append([]string(nil), tokens[:63]...), PadToken),
+		"pretty mark": append(append([]string(nil), tokens[:63]...), "a"+prettyPeriod),
+	} {
+		if _, err := parsePreset(strings.Join(bad, "\n")); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
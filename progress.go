@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// progressReader reports how much of a reader of known size has been read,
+// rewriting a single "read NN%" line on w whenever the percentage changes.
+type progressReader struct {
+	r     io.Reader
+	w     io.Writer
+	total int64
+	read  int64
+	shown int // last percentage written, or -1
+}
+
+func newProgressReader(r io.Reader, w io.Writer, total int64) *progressReader {
+	return &progressReader{r: r, w: w, total: total, shown: -1}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if pct := int(p.read * 100 / p.total); pct != p.shown && pct <= 100 {
+		p.shown = pct
+		fmt.Fprintf(p.w, "\rread %3d%%", pct)
+		if pct == 100 {
+			fmt.Fprintln(p.w)
+		}
+	}
+	return n, err
+}
+
+// isTerminal reports whether w is a character device such as a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
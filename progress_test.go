@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestProgressReader(t *testing.T) {
+	var sb strings.Builder
+	p := newProgressReader(iotest.OneByteReader(strings.NewReader("four")), &sb, 4)
+	if _, err := io.ReadAll(p); err != nil {
+		t.Fatal(err)
+	}
+	if want := "\rread  25%\rread  50%\rread  75%\rread 100%\n"; sb.String() != want {
+		t.Errorf("got %q, want %q", sb.String(), want)
+	}
+}
+
+func TestProgressNotOnTerminal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "in.txt")
+	if err := os.WriteFile(path, []byte("progress"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, stderr, err := runCLI(t, "", "encode", "--progress", "-f", path)
+	if err != nil || out != mustEncode(t, "progress")+"\n" {
+		t.Fatalf("--progress: %q, %v", out, err)
+	}
+	if stderr != "" {
+		t.Errorf("--progress wrote to a non-terminal: %q", stderr)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// qrAlphabet is the 45-character set of QR alphanumeric mode, in RFC 9285
+// (Base45) order.
+const qrAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// QRForm rewrites dog speech for QR codes, whose alphanumeric mode has no room
+// for CJK glyphs: the frame the tokens carry (length header and payload) is
+// written in Base45, 3 characters per 2 bytes, which is about 1.5 characters
+// per byte instead of 3 or more glyphs in byte mode. FromQRForm maps it back.
+func QRForm(dogSpeech string) (string, error) {
+	ids, err := DecodeToIDs(dogSpeech)
+	if err != nil {
+		return "", err
+	}
+	if _, err := unpackIDs(ids); err != nil {
+		return "", err
+	}
+	frame := packIDs(ids)
+	n := binary.BigEndian.Uint32(frame)
+	frame = frame[:4+int(n)] // drop trailing tokens and zero padding
+
+	var sb strings.Builder
+	sb.Grow((len(frame)*3 + 1) / 2)
+	for len(frame) >= 2 {
+		v := int(frame[0])<<8 | int(frame[1])
+		sb.WriteByte(qrAlphabet[v%45])
+		sb.WriteByte(qrAlphabet[v/45%45])
+		sb.WriteByte(qrAlphabet[v/45/45])
+		frame = frame[2:]
+	}
+	if len(frame) == 1 {
+		sb.WriteByte(qrAlphabet[frame[0]%45])
+		sb.WriteByte(qrAlphabet[frame[0]/45])
+	}
+	return sb.String(), nil
+}
+
+// FromQRForm turns the output of QRForm back into dog speech. Lowercase
+// letters are accepted, since some scanners report them.
+func FromQRForm(qr string) (string, error) {
+	qr = strings.ToUpper(qr)
+	if len(qr)%3 == 1 {
+		return "", fmt.Errorf("QR form has %d characters, which no byte count gives", len(qr))
+	}
+	digit := func(i int) (int, error) {
+		d := strings.IndexByte(qrAlphabet, qr[i])
+		if d < 0 {
+			return 0, fmt.Errorf("character %q at %d is not in the QR alphanumeric set", qr[i], i)
+		}
+		return d, nil
+	}
+
+	frame := make([]byte, 0, len(qr)*2/3)
+	for i := 0; i < len(qr); i += 3 {
+		width := min(3, len(qr)-i)
+		v, scale := 0, 1
+		for j := range width {
+			d, err := digit(i + j)
+			if err != nil {
+				return "", err
+			}
+			v += d * scale
+			scale *= 45
+		}
+		if width == 3 {
+			if v > 0xFFFF {
+				return "", fmt.Errorf("QR form group %q at %d is out of range", qr[i:i+3], i)
+			}
+			frame = append(frame, byte(v>>8), byte(v))
+		} else {
+			if v > 0xFF {
+				return "", fmt.Errorf("QR form group %q at %d is out of range", qr[i:], i)
+			}
+			frame = append(frame, byte(v))
+		}
+	}
+
+	if len(frame) < 4 {
+		return "", fmt.Errorf("QR form too short for the length header: %d bytes", len(frame))
+	}
+	if n := binary.BigEndian.Uint32(frame); uint64(n) != uint64(len(frame)-4) {
+		return "", fmt.Errorf("QR form carries %d payload bytes, but its header declares %d", len(frame)-4, n)
+	}
+	return EncodeBytes(frame[4:]), nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQRCLI(t *testing.T) {
+	// Frame 00 00 00 02 41 42 in Base45: 0x0000 → "000", 0x0002 → "200", 0x4142 → "BB8".
+	out, _, err := runCLI(t, "", "encode", "--qr", "AB")
+	if err != nil || out != "000200BB8\n" {
+		t.Fatalf("encode --qr: %q, %v", out, err)
+	}
+	if got, _, err := runCLI(t, out, "decode", "--qr"); err != nil || got != "AB\n" {
+		t.Errorf("decode --qr: %q, %v", got, err)
+	}
+	if _, _, err := runCLI(t, out, "decode"); err == nil {
+		t.Error("QR form decoded without --qr")
+	}
+
+	text := strings.Repeat("狗 woof ", 20)
+	out, _, err = runCLI(t, "", "encode", "--qr", text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Trim(out, qrAlphabet+"\n") != "" {
+		t.Errorf("encode --qr left the QR alphanumeric set: %q", out)
+	}
+	if got, _, err := runCLI(t, out, "decode", "--qr"); err != nil || got != text+"\n" {
+		t.Errorf("decode --qr of a long frame: %q, %v", got, err)
+	}
+	if _, _, err := runCLI(t, "", "encode", "--qr", "--spaceless", "AB"); err == nil {
+		t.Error("--qr with --spaceless: no error")
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package main
+
+// raceEnabled is set under -race, where sync.Pool drops some of what is put
+// back, so allocation measurements of pooled paths are off.
+const raceEnabled = true
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Renderer decorates each token on the way out of Encode. Normalize must undo
+// that decoration so Decode sees plain, whitespace-separated tokens again.
+type Renderer interface {
+	Render(token string) string
+	Normalize(dogSpeech string) string
+}
+
+// BracketRenderer wraps every token in Open and Close, e.g. 「汪」.
+type BracketRenderer struct {
+	Open, Close string
+}
+
+func (r BracketRenderer) Render(token string) string {
+	return r.Open + token + r.Close
+}
+
+func (r BracketRenderer) Normalize(dogSpeech string) string {
+	// Brackets become separators, so 「汪」「嗚」 decodes even without spaces.
+	return strings.NewReplacer(r.Open, " ", r.Close, " ").Replace(dogSpeech)
+}
+
+// renderers are the styles selectable with --style; "plain" means no renderer.
+var renderers = map[string]Renderer{
+	"brackets": BracketRenderer{Open: "「", Close: "」"},
+}
+
+// lookupRenderer returns the renderer for a --style name, or nil for "plain".
+func lookupRenderer(style string) (Renderer, error) {
+	if style == "" || style == "plain" {
+		return nil, nil
+	}
+	r, ok := renderers[style]
+	if !ok {
+		names := []string{"plain"}
+		for name := range renderers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown style %q (want one of %s)", style, strings.Join(names, ", "))
+	}
+	return r, nil
+}
+
+// renderTokens applies r to every space-separated token of out, keeping the separators.
+func renderTokens(out string, r Renderer) string {
+	var sb strings.Builder
+	start := 0
+	for i := 0; i <= len(out); i++ {
+		if i == len(out) || out[i] == ' ' || out[i] == '\n' {
+			sb.WriteString(r.Render(out[start:i]))
+			if i < len(out) {
+				sb.WriteByte(out[i])
+			}
+			start = i + 1
+		}
+	}
+	return sb.String()
+}
+
+// EncodeWith is Encode with every token passed through r.
+func EncodeWith(input string, r Renderer) (string, error) {
+	out, err := Encode(input)
+	if err != nil {
+		return "", err
+	}
+	return renderTokens(out, r), nil
+}
+
+// DecodeWith undoes r's decoration and then decodes like Decode.
+func DecodeWith(dogSpeech string, r Renderer) (string, error) {
+	return Decode(r.Normalize(dogSpeech))
+}
+
+// Punctuation used by Prettify. Neither mark occurs in the codebook, so Unprettify
+// can turn them back into separators.
+const (
+	prettyComma  = "，"
+	prettyPeriod = "。"
+)
+
+// prettyLengths are the sentence lengths Prettify cycles through, so the output
+// reads less mechanically while staying deterministic.
+var prettyLengths = []int{5, 8, 6, 9, 4, 7}
+
+// Prettify groups the space-separated tokens of dogSpeech into pseudo-sentences:
+// a comma after the middle token and a period after the last one of each.
+// Line breaks in dogSpeech are kept.
+func Prettify(dogSpeech string) string {
+	var sb strings.Builder
+	for li, line := range strings.Split(dogSpeech, "\n") {
+		if li > 0 {
+			sb.WriteByte('\n')
+		}
+		tokens := strings.Fields(line)
+		sentence := 0
+		for start := 0; start < len(tokens); sentence++ {
+			n := min(prettyLengths[sentence%len(prettyLengths)], len(tokens)-start)
+			if start > 0 {
+				sb.WriteByte(' ')
+			}
+			for i, tok := range tokens[start : start+n] {
+				if i > 0 {
+					sb.WriteByte(' ')
+				}
+				sb.WriteString(tok)
+				if i == n/2-1 && n >= 4 {
+					sb.WriteString(prettyComma)
+				}
+			}
+			sb.WriteString(prettyPeriod)
+			start += n
+		}
+	}
+	return sb.String()
+}
+
+// Unprettify undoes Prettify. Input without its punctuation is returned unchanged.
+func Unprettify(s string) string {
+	if !strings.Contains(s, prettyComma) && !strings.Contains(s, prettyPeriod) {
+		return s
+	}
+	return strings.NewReplacer(prettyComma, " ", prettyPeriod, " ").Replace(s)
+}
+
+// GroupTokens lays out the tokens of dogSpeech in groups of group tokens split
+// by two spaces, and starts a new line every line tokens (0 for no breaks).
+// Decode treats any run of whitespace as one separator, so the result decodes
+// the same.
+func GroupTokens(dogSpeech string, group, line int) string {
+	var sb strings.Builder
+	for i, tok := range strings.Fields(dogSpeech) {
+		switch {
+		case i == 0:
+		case line > 0 && i%line == 0:
+			sb.WriteByte('\n')
+		case group > 0 && i%group == 0:
+			sb.WriteString("  ")
+		default:
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(tok)
+	}
+	return sb.String()
+}
+
+// parseGroup reads a --group value, "SIZE" or "SIZE,LINE", e.g. "4,16".
+func parseGroup(v string) (group, line int, err error) {
+	g, l, hasLine := strings.Cut(v, ",")
+	if group, err = strconv.Atoi(g); err != nil || group < 1 {
+		return 0, 0, fmt.Errorf("bad --group %q (want SIZE or SIZE,LINE with positive numbers)", v)
+	}
+	if hasLine {
+		if line, err = strconv.Atoi(l); err != nil || line < 1 {
+			return 0, 0, fmt.Errorf("bad --group %q (want SIZE or SIZE,LINE with positive numbers)", v)
+		}
+	}
+	return group, line, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncodePretty(t *testing.T) {
+	f := strings.Fields(mustEncode(t, "abc"))
+	if len(f) != 10 {
+		t.Fatalf("%d tokens, want 10", len(f))
+	}
+	// Sentences of 5 and then at most 8 tokens, with a comma after the middle token.
+	want := f[0] + " " + f[1] + prettyComma + " " + f[2] + " " + f[3] + " " + f[4] + prettyPeriod + " " +
+		f[5] + " " + f[6] + prettyComma + " " + f[7] + " " + f[8] + " " + f[9] + prettyPeriod + "\n"
+	out, _, err := runCLI(t, "", "encode", "--pretty", "abc")
+	if err != nil || out != want {
+		t.Fatalf("--pretty: %q, %v; want %q", out, err, want)
+	}
+	if got, _, err := runCLI(t, out, "decode"); err != nil || got != "abc\n" {
+		t.Errorf("decode of --pretty output: %q, %v", got, err)
+	}
+	// Ids are not dog speech, so --pretty leaves them alone.
+	ids, _, _ := runCLI(t, "", "encode", "--ids", "abc")
+	if got, _, err := runCLI(t, "", "encode", "--pretty", "--ids", "abc"); err != nil || got != ids {
+		t.Errorf("--pretty with --ids: %q, %v; want %q", got, err, ids)
+	}
+}
+
+func TestEncodeGroup(t *testing.T) {
+	f := strings.Fields(mustEncode(t, "abc"))
+	want := strings.Join(f[0:2], " ") + "  " + strings.Join(f[2:4], " ") + "\n" +
+		strings.Join(f[4:6], " ") + "  " + strings.Join(f[6:8], " ") + "\n" +
+		strings.Join(f[8:10], " ") + "\n"
+	out, _, err := runCLI(t, "", "encode", "--group", "2,4", "abc")
+	if err != nil || out != want {
+		t.Fatalf("--group 2,4: %q, %v; want %q", out, err, want)
+	}
+	if got, _, err := runCLI(t, out, "decode"); err != nil || got != "abc\n" {
+		t.Errorf("decode of grouped output: %q, %v", got, err)
+	}
+	if out, _, _ := runCLI(t, "", "encode", "--group", "5", "abc"); out != strings.Join(f[:5], " ")+"  "+strings.Join(f[5:], " ")+"\n" {
+		t.Errorf("--group 5: %q", out)
+	}
+	for _, bad := range []string{"0", "4,0", "x", "4,"} {
+		if _, _, err := runCLI(t, "", "encode", "--group", bad, "abc"); err == nil || !strings.Contains(err.Error(), "bad --group") {
+			t.Errorf("--group %s: %v", bad, err)
+		}
+	}
+}
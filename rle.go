@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// runMark joins a token and its repeat count in run-length form, e.g. 汪×5.
+// init checks that no codebook token contains it, so expanding can't misread a token.
+const runMark = "×"
+
+// minRun is the shortest run CompactRuns collapses; shorter runs are not shorter as tok×n.
+const minRun = 3
+
+// maxRun caps the count of a single run.
+const maxRun = 1 << 20
+
+// maxExpandedTokens caps the tokens ExpandRuns produces in total, about 12 MB
+// of payload. With maxRun alone, many short runs in a small input could still
+// demand a huge allocation.
+const maxExpandedTokens = 1 << 24
+
+// CompactRuns rewrites every run of at least minRun identical tokens as
+// token×count. Line breaks are kept; runs never span lines.
+func CompactRuns(dogSpeech string) string {
+	lines := strings.Split(dogSpeech, "\n")
+	for i, line := range lines {
+		toks := strings.Split(line, " ")
+		out := make([]string, 0, len(toks))
+		for j := 0; j < len(toks); {
+			k := j + 1
+			for k < len(toks) && toks[k] == toks[j] {
+				k++
+			}
+			if n := k - j; n >= minRun && toks[j] != "" {
+				out = append(out, toks[j]+runMark+strconv.Itoa(n))
+			} else {
+				out = append(out, toks[j:k]...)
+			}
+			j = k
+		}
+		lines[i] = strings.Join(out, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ExpandRuns undoes CompactRuns, turning each token×count back into count
+// space-separated tokens. Input without run marks is returned unchanged; input
+// that would expand to more than maxExpandedTokens tokens is an error.
+func ExpandRuns(dogSpeech string) (string, error) {
+	if !strings.Contains(dogSpeech, runMark) {
+		return dogSpeech, nil
+	}
+	fields := strings.Fields(dogSpeech)
+	total := 0
+	for _, f := range fields {
+		_, n, err := parseRun(f)
+		if err != nil {
+			return "", err
+		}
+		if total += n; total > maxExpandedTokens {
+			return "", fmt.Errorf("runs expand to more than %d tokens", maxExpandedTokens)
+		}
+	}
+	out := make([]string, 0, total)
+	for _, f := range fields {
+		tok, n, _ := parseRun(f)
+		for range n {
+			out = append(out, tok)
+		}
+	}
+	return strings.Join(out, " "), nil
+}
+
+// parseRun splits a field into its token and repeat count; a field without a
+// run mark is its own token, once.
+func parseRun(f string) (string, int, error) {
+	tok, count, ok := strings.Cut(f, runMark)
+	if !ok {
+		return f, 1, nil
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 1 || tok == "" {
+		return "", 0, fmt.Errorf("malformed run %q (want token%scount)", f, runMark)
+	}
+	if n > maxRun {
+		return "", 0, fmt.Errorf("run %q exceeds the limit of %d repeats", f, maxRun)
+	}
+	return tok, n, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRunsRoundTrip(t *testing.T) {
+	for _, payload := range []string{"", "a", "aaaaaaaaaaaaaaaaaaaaaaaa", strings.Repeat("\x00", 100) + "end"} {
+		speech := EncodeBytes([]byte(payload))
+		compact := CompactRuns(speech)
+		if strings.Contains(payload, "\x00\x00\x00") && !strings.Contains(compact, runMark) {
+			t.Errorf("%q: no runs compacted in %q", payload, compact)
+		}
+		expanded, err := ExpandRuns(compact)
+		if err != nil {
+			t.Fatalf("%q: %v", payload, err)
+		}
+		if expanded != speech {
+			t.Fatalf("%q: expanded to %q, want %q", payload, expanded, speech)
+		}
+	}
+}
+
+func TestExpandRunsLimits(t *testing.T) {
+	tok := codebook[0]
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"malformed", tok + runMark + "x"},
+		{"zero", tok + runMark + "0"},
+		{"single run too long", tok + runMark + strconv.Itoa(maxRun+1)},
+		// Every run is within maxRun, but together they exceed maxExpandedTokens.
+		{"many runs", strings.TrimSpace(strings.Repeat(tok+runMark+strconv.Itoa(maxRun)+" ", maxExpandedTokens/maxRun+1))},
+	}
+	for _, tt := range tests {
+		if _, err := ExpandRuns(tt.input); err == nil {
+			t.Errorf("%s: ExpandRuns accepted %.40q", tt.name, tt.input)
+		}
+	}
+}
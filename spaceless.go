@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The spaceless form needs no separators. It uses only 16 tokens taken from the
+// main codebook, in core-major order: the three single-rune cores (汪 嗚 嗷),
+// each followed by one of the single-rune tones . ~ ～ … ! ！. Every token is
+// exactly two runes, so the set is prefix-free and text like "汪.嗚～嗷…" splits
+// without spaces. Each token carries only 4 bits instead of 6, so a message
+// takes 2 tokens per byte (4+n bytes framed) rather than about 1.33.
+var (
+	spacelessCodebook [16]string
+	spacelessTable    map[string]byte
+)
+
+func init() {
+	spacelessTable = make(map[string]byte, len(spacelessCodebook))
+	i := 0
+	for _, c := range cores[:3] {
+		for _, t := range tones[1:7] {
+			if i == len(spacelessCodebook) {
+				break
+			}
+			token := c + t
+			if _, ok := reverseTable[token]; !ok || utf8.RuneCountInString(token) != 2 {
+				panic("spaceless token is not a two-rune codebook token: " + token)
+			}
+			spacelessCodebook[i] = token
+			spacelessTable[token] = byte(i)
+			i++
+		}
+	}
+}
+
+// EncodeSpaceless is like Encode but writes the separator-free form described above.
+func EncodeSpaceless(input string) (string, error) {
+	input = toNFC(input)
+	if !utf8.ValidString(input) {
+		return "", errInvalidInput
+	}
+	return EncodeSpacelessBytes([]byte(input)), nil
+}
+
+// EncodeSpacelessBytes is the byte-level counterpart of EncodeSpaceless.
+func EncodeSpacelessBytes(payload []byte) string {
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(payload)))
+
+	var sb strings.Builder
+	sb.Grow(2 * (4 + len(payload)) * 6)
+	for _, part := range [][]byte{header[:], payload} {
+		for _, b := range part {
+			sb.WriteString(spacelessCodebook[b>>4])
+			sb.WriteString(spacelessCodebook[b&0x0F])
+		}
+	}
+	return sb.String()
+}
+
+// DecodeSpaceless turns spaceless dog speech back into the original UTF-8 text.
+func DecodeSpaceless(dogSpeech string) (string, error) {
+	payload, err := DecodeSpacelessBytes(dogSpeech)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(payload) {
+		return "", errInvalidPayload
+	}
+	return string(payload), nil
+}
+
+// DecodeSpacelessBytes reads two runes at a time as one 4-bit token. Whitespace
+// anywhere in the input is ignored, so wrapped or spaced copies decode too.
+func DecodeSpacelessBytes(dogSpeech string) ([]byte, error) {
+	dogSpeech, _ = trimPadToken(dogSpeech)
+	s := toNFC(strings.Join(strings.FieldsFunc(dogSpeech, unicode.IsSpace), ""))
+	if s == "" {
+		return nil, fmt.Errorf("empty input")
+	}
+
+	var bytesOut []byte
+	var hi byte
+	tokens := 0
+	for s != "" {
+		_, n1 := utf8.DecodeRuneInString(s)
+		_, n2 := utf8.DecodeRuneInString(s[n1:])
+		tok := s[:n1+n2]
+		s = s[n1+n2:]
+		id, ok := spacelessTable[tok]
+		if !ok {
+			return nil, fmt.Errorf("unknown spaceless token: %q", tok)
+		}
+		if tokens%2 == 0 {
+			hi = id
+		} else {
+			bytesOut = append(bytesOut, hi<<4|id)
+		}
+		tokens++
+	}
+
+	if len(bytesOut) < 4 {
+		return nil, fmt.Errorf("decoded data too short (missing length header): got %d tokens, need at least 8", tokens)
+	}
+	return unframe(bytesOut, tokens)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSpacelessCLI(t *testing.T) {
+	out, _, err := runCLI(t, "", "encode", "--spaceless", "hi汪")
+	if err != nil {
+		t.Fatal(err)
+	}
+	speech := strings.TrimSuffix(out, "\n")
+	// 4 header bytes and 5 payload bytes, two 2-rune tokens per byte.
+	if strings.ContainsRune(speech, ' ') || utf8.RuneCountInString(speech) != 2*2*(4+5) {
+		t.Errorf("--spaceless: %q", speech)
+	}
+	if got, _, err := runCLI(t, "", "decode", "--spaceless", speech); err != nil || got != "hi汪\n" {
+		t.Errorf("decode --spaceless: %q, %v", got, err)
+	}
+	if _, _, err := runCLI(t, "", "decode", speech); err == nil {
+		t.Error("spaceless form decoded without --spaceless")
+	}
+	if _, _, err := runCLI(t, "", "encode", "--spaceless", "--ids", "hi"); err == nil {
+		t.Error("--spaceless with --ids: no error")
+	}
+}
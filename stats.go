@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// tokenStats counts the tokens of some dog speech by core and by tone. The
+// codebook is core-major, so token id i is core i/8 with tone i%8.
+type tokenStats struct {
+	Tokens int
+	Cores  [8]int
+	Tones  [8]int
+}
+
+func tokenStatsOf(dogSpeech string) (tokenStats, error) {
+	dogSpeech, _ = trimPadToken(dogSpeech)
+	ids, err := DecodeToIDs(dogSpeech)
+	if err != nil {
+		return tokenStats{}, err
+	}
+	st := tokenStats{Tokens: len(ids)}
+	for _, id := range ids {
+		st.Cores[id/8]++
+		st.Tones[id%8]++
+	}
+	return st, nil
+}
+
+// histogram lists every core, then every tone, with its count and share.
+func (st tokenStats) histogram() string {
+	var sb strings.Builder
+	row := func(kind, name string, n int) {
+		fmt.Fprintf(&sb, "\n%s %s\t%d\t%.1f%%", kind, name, n, 100*float64(n)/float64(max(st.Tokens, 1)))
+	}
+	for i, n := range st.Cores {
+		row("core", cores[i], n)
+	}
+	for i, n := range st.Tones {
+		name := tones[i]
+		if name == "" {
+			name = "(none)"
+		}
+		row("tone", name, n)
+	}
+	return sb.String()
+}
+
+func newStatsCmd(inFile, outFile *string) *cobra.Command {
+	var byToken bool
+	cmd := &cobra.Command{
+		Use:   "stats [dog-speech]",
+		Short: "Count the tokens of dog speech, and with --tokens how often each core and tone appears",
+		Args:  cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			input, err := inputFromArgsOrStdin(args, *inFile, nil, cmd.InOrStdin())
+			if err != nil {
+				return fmt.Errorf("read input error: %w", err)
+			}
+			st, err := tokenStatsOf(input)
+			if err != nil {
+				return fmt.Errorf("stats error: %w", err)
+			}
+			out := fmt.Sprintf("tokens: %d", st.Tokens)
+			if payload, err := DecodeBytes(input); err == nil {
+				out += fmt.Sprintf("\npayload bytes: %d", len(payload))
+			}
+			if byToken {
+				out += st.histogram()
+			}
+			if err := writeResult(cmd.OutOrStdout(), *outFile, out); err != nil {
+				return fmt.Errorf("write output error: %w", err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&byToken, "tokens", false, "add a histogram of the cores and tones used")
+	return cmd
+}
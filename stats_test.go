@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestStatsTokens(t *testing.T) {
+	// "a" is ids 0 0 0 0 0 22 4: core 0 six times and core 2 once; tones 0, 4 and 6.
+	speech := mustEncode(t, "a")
+	out, _, err := runCLI(t, "", "stats", speech)
+	if err != nil || out != "tokens: 7\npayload bytes: 1\n" {
+		t.Fatalf("stats: %q, %v", out, err)
+	}
+
+	out, _, err = runCLI(t, "", "stats", "--tokens", speech+" "+PadToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != 2+len(cores)+len(tones) || lines[0] != "tokens: 7" {
+		t.Fatalf("stats --tokens:\n%s", out)
+	}
+	coreCounts := map[int]int{0: 6, 2: 1}
+	for i := range cores {
+		n := coreCounts[i]
+		if want := fmt.Sprintf("core %s\t%d\t%.1f%%", cores[i], n, 100*float64(n)/7); lines[2+i] != want {
+			t.Errorf("core %d: %q, want %q", i, lines[2+i], want)
+		}
+	}
+	toneCounts := map[int]int{0: 5, 4: 1, 6: 1}
+	for i := range tones {
+		name := tones[i]
+		if name == "" {
+			name = "(none)"
+		}
+		n := toneCounts[i]
+		if want := fmt.Sprintf("tone %s\t%d\t%.1f%%", name, n, 100*float64(n)/7); lines[2+len(cores)+i] != want {
+			t.Errorf("tone %d: %q, want %q", i, lines[2+len(cores)+i], want)
+		}
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"unicode"
+	"unicode/utf8"
+)
+
+// streamChunkSize is how much input a Decoder asks its reader for at a time.
+const streamChunkSize = 32 * 1024
+
+// Decoder reads dog speech from an io.Reader and yields the payload bytes as
+// the tokens arrive, so the input never has to be held in memory at once.
+// Like DecodeFirst it stops at the end of the frame and ignores what follows.
+type Decoder struct {
+	r   io.Reader
+	buf []byte // unread input; may end in the middle of a token or a rune
+	mem []byte // the whole buffer buf is a window of
+	eof bool
+
+	bitBuf   uint32
+	bitCount uint8
+	tokens   int
+
+	header    []byte
+	remaining int64 // payload bytes still to come, or -1 until the header is read
+	have      int64 // payload bytes produced so far
+	out       []byte
+	err       error
+
+	checkUTF8 bool
+	valid     int // length of the prefix of out known to be complete, valid UTF-8
+
+	runs    bool   // expand token×count runs (CompactRuns) as they arrive
+	runTok  string // token of the current run
+	pending int    // repeats of runTok still to come
+}
+
+// NewDecoder returns a Decoder reading dog speech from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r, remaining: -1}
+}
+
+// NewUTF8Decoder is like NewDecoder, but the payload must be valid UTF-8. It is
+// checked as it is produced, holding back only an incomplete trailing rune, so
+// Read fails with the invalid-payload error as soon as a bad byte shows up.
+func NewUTF8Decoder(r io.Reader) *Decoder {
+	return &Decoder{r: r, remaining: -1, checkUTF8: true}
+}
+
+// Read implements io.Reader over the decoded payload.
+func (d *Decoder) Read(p []byte) (int, error) {
+	for d.ready() == 0 && d.err == nil {
+		d.step()
+	}
+	if ready := d.ready(); ready > 0 {
+		n := copy(p, d.out[:ready])
+		d.out = d.out[n:]
+		if d.checkUTF8 {
+			d.valid -= n
+		}
+		return n, nil
+	}
+	return 0, d.err
+}
+
+// ready returns how many bytes of d.out may be handed out.
+func (d *Decoder) ready() int {
+	if d.checkUTF8 {
+		return d.valid
+	}
+	return len(d.out)
+}
+
+// validate extends d.valid over the complete runes at the end of d.out. On an
+// invalid byte it drops everything from there on and sets d.err.
+func (d *Decoder) validate() {
+	for d.valid < len(d.out) && utf8.FullRune(d.out[d.valid:]) {
+		r, n := utf8.DecodeRune(d.out[d.valid:])
+		if r == utf8.RuneError && n == 1 {
+			d.out = d.out[:d.valid]
+			d.err = errInvalidPayload
+			return
+		}
+		d.valid += n
+	}
+}
+
+// step consumes one token, or sets d.err once the frame is complete or broken.
+func (d *Decoder) step() {
+	if d.remaining == 0 {
+		d.err = io.EOF
+		if d.checkUTF8 && d.valid < len(d.out) {
+			// The payload ends in the middle of a rune.
+			d.out = d.out[:d.valid]
+			d.err = errInvalidPayload
+		}
+		return
+	}
+
+	var tok string
+	if d.pending > 0 {
+		tok = d.runTok
+		d.pending--
+	} else {
+		b, err := d.nextToken()
+		if err != nil {
+			d.err = err
+			return
+		}
+		if b == nil {
+			switch {
+			case d.tokens == 0:
+				d.err = errors.New("empty input")
+			case d.remaining < 0:
+				d.err = shortError(d.tokens)
+			default:
+				d.err = incompleteError(uint64(d.have+d.remaining), int(d.have), d.tokens)
+			}
+			return
+		}
+		tok = toNFC(string(b))
+		if d.runs {
+			n := 0
+			if tok, n, d.err = parseRun(tok); d.err != nil {
+				return
+			}
+			d.runTok, d.pending = tok, n-1
+		}
+	}
+
+	id, ok := lookupToken(tok)
+	if !ok {
+		d.err = fmt.Errorf("unknown token: %q", tok)
+		return
+	}
+	d.tokens++
+
+	d.bitBuf = (d.bitBuf << 6) | uint32(id&0x3F)
+	d.bitCount += 6
+	if d.bitCount < 8 {
+		return
+	}
+	shift := d.bitCount - 8
+	b := byte((d.bitBuf >> shift) & 0xFF)
+	d.bitCount -= 8
+	d.bitBuf = d.bitBuf & ((1 << d.bitCount) - 1)
+
+	if d.remaining < 0 {
+		d.header = append(d.header, b)
+		if len(d.header) == 4 {
+			d.remaining = int64(binary.BigEndian.Uint32(d.header))
+		}
+		return
+	}
+	d.out = append(d.out, b)
+	d.remaining--
+	d.have++
+	if d.checkUTF8 {
+		d.validate()
+	}
+}
+
+// drain reads the input left after the frame and fails on the first field that
+// is not a token (or a trailing PadToken), so that, like the CLI's one-shot
+// decode, trailing garbage is an error. It must only be called once Read has returned io.EOF.
+func (d *Decoder) drain() error {
+	for {
+		tok, err := d.nextToken()
+		if err != nil || tok == nil {
+			return err
+		}
+		t := toNFC(string(tok))
+		if d.runs {
+			if t, _, err = parseRun(t); err != nil {
+				return err
+			}
+		}
+		if _, ok := lookupToken(t); !ok && t != PadToken {
+			return fmt.Errorf("unknown token: %q", t)
+		}
+	}
+}
+
+// nextToken returns the next whitespace-separated token, reading more input
+// until one is complete. A token (or a rune in it) split across reads is kept
+// in d.buf until the rest arrives. It returns nil at the end of the input. The
+// token is only valid until the next call.
+func (d *Decoder) nextToken() ([]byte, error) {
+	for {
+		d.buf = bytes.TrimLeftFunc(d.buf, unicode.IsSpace)
+		if i := bytes.IndexFunc(d.buf, unicode.IsSpace); i >= 0 {
+			tok := d.buf[:i]
+			d.buf = d.buf[i:]
+			return tok, nil
+		}
+		if d.eof {
+			if len(d.buf) == 0 {
+				return nil, nil
+			}
+			tok := d.buf
+			d.buf = nil
+			return tok, nil
+		}
+
+		// Make room for a read after the partial token. It slides to the front
+		// when what was consumed before it is at least as long, and otherwise
+		// the buffer doubles, so each input byte is copied a bounded number of
+		// times however long a token grows.
+		if cap(d.buf)-len(d.buf) < streamChunkSize {
+			consumed := cap(d.mem) - cap(d.buf)
+			if consumed >= len(d.buf) && cap(d.mem)-len(d.buf) >= streamChunkSize {
+				d.buf = d.mem[:copy(d.mem, d.buf)]
+			} else {
+				next := make([]byte, len(d.buf), 2*len(d.buf)+streamChunkSize)
+				copy(next, d.buf)
+				d.buf, d.mem = next, next[:cap(next)]
+			}
+		}
+		n, err := d.r.Read(d.buf[len(d.buf):cap(d.buf)])
+		d.buf = d.buf[:len(d.buf)+n]
+		if err == io.EOF {
+			d.eof = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Encoder writes the dog speech for a payload whose length is known up front,
+// so the header can go out first and the payload is streamed through Write.
+// The complete output is identical to EncodeBytes of the same bytes.
+type Encoder struct {
+	w         io.Writer
+	remaining int64 // payload bytes still expected
+	bitBuf    uint32
+	bitCount  uint8
+	tokens    int
+	out       []byte
+	err       error
+}
+
+// NewEncoderSize returns an Encoder for a payload of exactly n bytes. It writes
+// the header tokens to w on the first Write or Close. Close must be called to
+// write the final token.
+func NewEncoderSize(w io.Writer, n int64) *Encoder {
+	e := &Encoder{w: w, remaining: n}
+	if n < 0 || n > math.MaxUint32 {
+		e.err = fmt.Errorf("payload size %d does not fit the 4-byte length header", n)
+		return e
+	}
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(n))
+	e.push(header[:])
+	return e
+}
+
+// Write encodes p. Writing more than the size given to NewEncoderSize is an error.
+func (e *Encoder) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	if int64(len(p)) > e.remaining {
+		e.err = fmt.Errorf("write exceeds the declared payload size by %d bytes", int64(len(p))-e.remaining)
+		return 0, e.err
+	}
+	e.push(p)
+	e.remaining -= int64(len(p))
+	if err := e.flush(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close writes the last, zero-padded token. It fails if fewer bytes were
+// written than declared, since the header would then be wrong.
+func (e *Encoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.remaining > 0 {
+		e.err = fmt.Errorf("closed with %d bytes of the declared payload still unwritten", e.remaining)
+		return e.err
+	}
+	if e.bitCount > 0 {
+		e.emit(byte(e.bitBuf << (6 - e.bitCount)))
+		e.bitCount, e.bitBuf = 0, 0
+	}
+	if err := e.flush(); err != nil {
+		return err
+	}
+	e.err = errors.New("encoder closed")
+	return nil
+}
+
+// push turns p into tokens appended to e.out.
+func (e *Encoder) push(p []byte) {
+	for _, b := range p {
+		e.bitBuf = (e.bitBuf << 8) | uint32(b)
+		e.bitCount += 8
+		for e.bitCount >= 6 {
+			e.bitCount -= 6
+			e.emit(byte(e.bitBuf >> e.bitCount))
+			e.bitBuf &= (1 << e.bitCount) - 1
+		}
+	}
+}
+
+func (e *Encoder) emit(id byte) {
+	if e.tokens > 0 {
+		e.out = append(e.out, ' ')
+	}
+	e.out = append(e.out, codebook[id&0x3F]...)
+	e.tokens++
+}
+
+func (e *Encoder) flush() error {
+	if len(e.out) == 0 {
+		return nil
+	}
+	_, err := e.w.Write(e.out)
+	e.out = e.out[:0]
+	if err != nil {
+		e.err = err
+	}
+	return err
+}
+
+// encoderStateMagic starts an Encoder state; the last byte is the layout version.
+const encoderStateMagic = "WFE\x01"
+
+// MarshalState captures where e is in its payload: the bytes still expected,
+// the bits not yet written as a token, the tokens written so far and any output
+// not yet flushed to the writer. UnmarshalState or ResumeEncoder continues from
+// it in another process, and the combined output is the same as one
+// uninterrupted encode. Save the state only after the last Write has returned,
+// so it matches what the writer has received.
+func (e *Encoder) MarshalState() ([]byte, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	b := make([]byte, 0, len(encoderStateMagic)+8+4+1+8+binary.MaxVarintLen64+len(e.out))
+	b = append(b, encoderStateMagic...)
+	b = binary.BigEndian.AppendUint64(b, uint64(e.remaining))
+	b = binary.BigEndian.AppendUint32(b, e.bitBuf)
+	b = append(b, e.bitCount)
+	b = binary.BigEndian.AppendUint64(b, uint64(e.tokens))
+	b = binary.AppendUvarint(b, uint64(len(e.out)))
+	return append(b, e.out...), nil
+}
+
+// UnmarshalState replaces e's progress with a state from MarshalState. e keeps
+// its writer, which must be where the saved encode was writing to.
+func (e *Encoder) UnmarshalState(state []byte) error {
+	bad := errors.New("malformed encoder state")
+	rest, ok := bytes.CutPrefix(state, []byte(encoderStateMagic))
+	if !ok || len(rest) < 8+4+1+8 {
+		return bad
+	}
+	remaining := int64(binary.BigEndian.Uint64(rest))
+	bitBuf := binary.BigEndian.Uint32(rest[8:])
+	bitCount := rest[12]
+	tokens := int64(binary.BigEndian.Uint64(rest[13:]))
+	rest = rest[21:]
+	n, w := binary.Uvarint(rest)
+	if w <= 0 || n != uint64(len(rest)-w) {
+		return bad
+	}
+	if remaining < 0 || remaining > math.MaxUint32 || tokens < 0 || bitCount >= 6 || bitBuf>>bitCount != 0 {
+		return bad
+	}
+	e.remaining, e.bitBuf, e.bitCount, e.tokens = remaining, bitBuf, bitCount, int(tokens)
+	e.out = append(e.out[:0], rest[w:]...)
+	e.err = nil
+	return nil
+}
+
+// ResumeEncoder returns an Encoder writing to w that continues from a state
+// saved with MarshalState.
+func ResumeEncoder(w io.Writer, state []byte) (*Encoder, error) {
+	e := &Encoder{w: w}
+	if err := e.UnmarshalState(state); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecoderSplitReads(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 3, 50, 1000} {
+		payload := bytes.Repeat([]byte("汪 woof "), n)[:n]
+		speech := EncodeBytes(payload)
+		for name, r := range map[string]io.Reader{
+			"whole":    strings.NewReader(speech),
+			"one byte": iotest.OneByteReader(strings.NewReader(speech)),
+			"3 bytes":  &smallReader{strings.NewReader(speech), 3}, // splits the 3-byte runes of a token
+			"trailing": strings.NewReader(speech + " " + speech + " not tokens"),
+		} {
+			got, err := io.ReadAll(NewDecoder(r))
+			if err != nil {
+				t.Fatalf("%d bytes, %s: %v", n, name, err)
+			}
+			if !bytes.Equal(got, payload) {
+				t.Fatalf("%d bytes, %s: got %q", n, name, got)
+			}
+		}
+	}
+}
+
+// smallReader returns at most n bytes per Read.
+type smallReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *smallReader) Read(p []byte) (int, error) {
+	return r.r.Read(p[:min(len(p), r.n)])
+}
+
+// A token far longer than a read must not be copied again on every read.
+func TestDecoderLongToken(t *testing.T) {
+	speech := strings.Repeat("x", 4<<20) + " " + mustEncode(t, "woof")
+	allocs := testing.AllocsPerRun(1, func() {
+		if _, err := io.ReadAll(NewDecoder(strings.NewReader(speech))); err == nil || !strings.Contains(err.Error(), "unknown token") {
+			t.Fatalf("got %v, want the long field rejected", err)
+		}
+	})
+	if allocs > 64 {
+		t.Errorf("%v allocations reading a 4 MiB token", allocs)
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	fields := strings.Fields(EncodeBytes([]byte("cut short")))
+	for _, in := range []string{"", "   ", strings.Join(fields[:3], " "), strings.Join(fields[:len(fields)-2], " "), fields[0] + " woof"} {
+		if _, err := io.ReadAll(NewDecoder(strings.NewReader(in))); err == nil {
+			t.Errorf("%q: no error", in)
+		}
+	}
+}
+
+// encodeInPieces streams payload through an Encoder in writes of size bytes.
+func encodeInPieces(t *testing.T, payload []byte, size int) string {
+	t.Helper()
+	var sb strings.Builder
+	e := NewEncoderSize(&sb, int64(len(payload)))
+	for p := payload; len(p) > 0; p = p[min(size, len(p)):] {
+		if _, err := e.Write(p[:min(size, len(p))]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return sb.String()
+}
+
+func TestEncoderMatchesEncodeBytes(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 3, 4, 100} {
+		payload := bytes.Repeat([]byte{0x00, 0xff, 'w', 0x80}, n)[:n]
+		for _, size := range []int{1, 2, 7, 1000} {
+			if got, want := encodeInPieces(t, payload, size), EncodeBytes(payload); got != want {
+				t.Fatalf("%d bytes in writes of %d: got %q, want %q", n, size, got, want)
+			}
+		}
+	}
+}
+
+func TestEncoderSizeMismatch(t *testing.T) {
+	e := NewEncoderSize(io.Discard, 3)
+	if _, err := e.Write([]byte("four")); err == nil {
+		t.Error("Write past the declared size succeeded")
+	}
+	e = NewEncoderSize(io.Discard, 3)
+	if _, err := e.Write([]byte("ab")); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err == nil {
+		t.Error("Close with a byte missing succeeded")
+	}
+	if err := NewEncoderSize(io.Discard, -1).Close(); err == nil {
+		t.Error("negative size accepted")
+	}
+}
+
+func TestUTF8Decoder(t *testing.T) {
+	text := strings.Repeat("汪🐶é", 40)
+	got, err := io.ReadAll(NewUTF8Decoder(iotest.OneByteReader(strings.NewReader(mustEncode(t, text)))))
+	if err != nil || string(got) != text {
+		t.Fatalf("got %q, %v", got, err)
+	}
+
+	// The bad byte comes after 10 valid ones; those are returned first.
+	bad := append([]byte("0123456789"), 0xff, 'x')
+	got, err = io.ReadAll(NewUTF8Decoder(strings.NewReader(EncodeBytes(bad))))
+	if err != errInvalidPayload || string(got) != "0123456789" {
+		t.Fatalf("invalid byte: got %q, %v", got, err)
+	}
+	// A rune cut off by the end of the payload is invalid too.
+	_, err = io.ReadAll(NewUTF8Decoder(strings.NewReader(EncodeBytes([]byte("汪")[:2]))))
+	if err != errInvalidPayload {
+		t.Fatalf("truncated rune: %v", err)
+	}
+}
+
+func TestEncoderResume(t *testing.T) {
+	payload := []byte("resumable encodes pick up mid-token")
+	for cut := 0; cut <= len(payload); cut++ {
+		var sb strings.Builder
+		e := NewEncoderSize(&sb, int64(len(payload)))
+		if _, err := e.Write(payload[:cut]); err != nil {
+			t.Fatal(err)
+		}
+		state, err := e.MarshalState()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := ResumeEncoder(&sb, state)
+		if err != nil {
+			t.Fatalf("cut %d: %v", cut, err)
+		}
+		if _, err := r.Write(payload[cut:]); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := sb.String(), EncodeBytes(payload); got != want {
+			t.Fatalf("cut %d: got %q, want %q", cut, got, want)
+		}
+	}
+}
+
+func TestEncoderStateMalformed(t *testing.T) {
+	state, err := NewEncoderSize(io.Discard, 10).MarshalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range [][]byte{nil, []byte("WFE\x02"), state[:len(state)-1], append(append([]byte(nil), state...), 'x')} {
+		if _, err := ResumeEncoder(io.Discard, bad); err == nil {
+			t.Errorf("ResumeEncoder accepted %q", bad)
+		}
+	}
+}
+
+func TestStreamDecodeFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := dir + "/" + name
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	text := strings.Repeat("\x00", 40) + "runs and a pad"
+	speech := mustEncode(t, text)
+
+	for name, content := range map[string]string{
+		"plain":   speech,
+		"runs":    CompactRuns(speech),
+		"padded":  speech + " " + PadToken + "\n",
+		"trailer": speech + " " + speech,
+	} {
+		var out bytes.Buffer
+		n, ok, err := streamDecodeFile(&out, write(name, content), nil, true, false, false)
+		if err != nil || !ok || n != int64(len(text)) || out.String() != text+"\n" {
+			t.Fatalf("%s: wrote %q (n=%d, ok=%v), %v", name, out.String(), n, ok, err)
+		}
+	}
+
+	// Not plain tokens: left for the one-shot path, with nothing written.
+	var out bytes.Buffer
+	if _, ok, err := streamDecodeFile(&out, write("armored", Armor(speech, countTokens(speech))), nil, true, false, false); ok || err != nil || out.Len() > 0 {
+		t.Fatalf("armored input: ok=%v, %v, wrote %q", ok, err, out.String())
+	}
+
+	// Garbage after the frame, past what the peek looks at, fails only once
+	// the payload is out.
+	text = strings.Repeat("long ", streamPeekSize)
+	out.Reset()
+	_, ok, err := streamDecodeFile(&out, write("garbage", mustEncode(t, text)+" not tokens"), nil, true, false, false)
+	if !ok || err == nil || out.String() != text {
+		t.Fatalf("trailing garbage: ok=%v, %v, wrote %q", ok, err, out.String())
+	}
+}
+
+func TestStreamMatchesDecodeLargeFile(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; sb.Len() < 1<<20; i++ {
+		fmt.Fprintf(&sb, "line %d: 汪汪 woof 🐶\n", i)
+	}
+	text := sb.String()
+	path := filepath.Join(t.TempDir(), "large.woof")
+	if err := os.WriteFile(path, []byte(mustEncode(t, text)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want, err := Decode(mustEncode(t, text))
+	if err != nil || want != text {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	var out bytes.Buffer
+	if _, ok, err := streamDecodeFile(&out, path, nil, true, false, false); !ok || err != nil || out.String() != want+"\n" {
+		t.Fatalf("streamDecodeFile: ok=%v, %v, %d bytes", ok, err, out.Len())
+	}
+	for _, args := range [][]string{{"decode", "-f", path}, {"decode", "--stream=false", "-f", path}} {
+		got, _, err := runCLI(t, "", args...)
+		if err != nil || got != want+"\n" {
+			t.Errorf("%v: %d bytes, %v; want %d bytes", args, len(got), err, len(want)+1)
+		}
+	}
+}
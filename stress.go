@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// stressStats counts how decoding fared over corrupted copies of one message.
+type stressStats struct {
+	Trials    int // copies decoded
+	Untouched int // trials in which no token happened to be replaced
+	Corrupted int // tokens replaced over all trials
+	Detected  int // decode returned an error
+	Silent    int // decode succeeded with the wrong bytes
+	Harmless  int // decode still produced the original (e.g. only padding bits hit)
+}
+
+// stressDecode encodes payload, then for each trial replaces every token with a
+// different random token with probability rate and decodes the result.
+func stressDecode(payload []byte, rate float64, trials int, rng *rand.Rand) stressStats {
+	tokens := strings.Fields(EncodeBytes(payload))
+	corrupted := make([]string, len(tokens))
+	var st stressStats
+	for range trials {
+		copy(corrupted, tokens)
+		hit := false
+		for i := range corrupted {
+			if rng.Float64() < rate {
+				id := reverseTable[corrupted[i]]
+				corrupted[i] = codebook[(int(id)+1+rng.IntN(len(codebook)-1))%len(codebook)]
+				st.Corrupted++
+				hit = true
+			}
+		}
+		st.Trials++
+		if !hit {
+			st.Untouched++
+			continue
+		}
+		got, err := DecodeBytes(strings.Join(corrupted, " "))
+		switch {
+		case err != nil:
+			st.Detected++
+		case bytes.Equal(got, payload):
+			st.Harmless++
+		default:
+			st.Silent++
+		}
+	}
+	return st
+}
+
+func (st stressStats) String() string {
+	// Percentages are of the trials that had at least one token replaced.
+	pct := func(n int) float64 { return 100 * float64(n) / float64(max(st.Trials-st.Untouched, 1)) }
+	return fmt.Sprintf("trials: %d (%d untouched)\ncorrupted tokens: %d\ndetected: %d (%.1f%%)\nsilent: %d (%.1f%%)\nharmless: %d (%.1f%%)",
+		st.Trials, st.Untouched, st.Corrupted, st.Detected, pct(st.Detected), st.Silent, pct(st.Silent), st.Harmless, pct(st.Harmless))
+}
+
+func newStressCmd(inFile, outFile *string) *cobra.Command {
+	var rate float64
+	var trials int
+	var seed uint64
+	cmd := &cobra.Command{
+		Use:   "stress [text]",
+		Short: "Corrupt random tokens of the encoded input and report how often decode notices",
+		Args:  cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if rate < 0 || rate > 1 {
+				return errors.New("--corrupt-rate must be between 0 and 1")
+			}
+			if trials <= 0 {
+				return errors.New("--trials must be positive")
+			}
+			input, err := inputFromArgsOrStdin(args, *inFile, nil, cmd.InOrStdin())
+			if err != nil {
+				return fmt.Errorf("read input error: %w", err)
+			}
+			rng := rand.New(rand.NewPCG(seed, seed))
+			st := stressDecode([]byte(input), rate, trials, rng)
+			if err := writeResult(cmd.OutOrStdout(), *outFile, st.String()); err != nil {
+				return fmt.Errorf("write output error: %w", err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().Float64Var(&rate, "corrupt-rate", 0.01, "probability that each token is replaced by another token")
+	cmd.Flags().IntVar(&trials, "trials", 1000, "number of corrupted copies to decode")
+	cmd.Flags().Uint64Var(&seed, "seed", 1, "random seed, so runs are reproducible")
+	return cmd
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+func TestStressFixedSeed(t *testing.T) {
+	args := []string{"stress", "--seed", "7", "--trials", "200", "--corrupt-rate", "0.05", "stress me, woof"}
+	first, _, err := runCLI(t, "", args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "trials: 200 (53 untouched)\ncorrupted tokens: 259\ndetected: 48 (32.7%)\nsilent: 99 (67.3%)\nharmless: 0 (0.0%)\n"
+	if first != want {
+		t.Errorf("got\n%s\nwant\n%s", first, want)
+	}
+	if again, _, err := runCLI(t, "", args...); err != nil || again != first {
+		t.Errorf("second run with the same seed: %q, %v", again, err)
+	}
+
+	// Nothing corrupted: every trial untouched, no decode fails.
+	st := stressDecode([]byte("clean"), 0, 100, rand.New(rand.NewPCG(1, 1)))
+	if st != (stressStats{Trials: 100, Untouched: 100}) {
+		t.Errorf("rate 0: %+v", st)
+	}
+	st = stressDecode([]byte("every token"), 1, 100, rand.New(rand.NewPCG(1, 1)))
+	if st.Untouched != 0 || st.Detected+st.Silent+st.Harmless != st.Trials {
+		t.Errorf("rate 1: %+v", st)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// watchInterval is how often the input file is polled in watch mode.
+const watchInterval = 500 * time.Millisecond
+
+// fileChanges is the watch backend: the returned channel receives whenever the
+// file at path changes and closes when ctx is done. Polling works everywhere,
+// so no native notification API is needed.
+var fileChanges = pollChanges
+
+// pollChanges compares the file's size and modification time every interval.
+func pollChanges(ctx context.Context, path string, interval time.Duration) <-chan struct{} {
+	ch := make(chan struct{})
+	go func() {
+		defer close(ch)
+		last, _ := os.Stat(path)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			fi, err := os.Stat(path)
+			if err != nil || (last != nil && fi.Size() == last.Size() && fi.ModTime().Equal(last.ModTime())) {
+				continue
+			}
+			last = fi
+			select {
+			case ch <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// watch calls run once, then again after every change to path until ctx is
+// done. Only the first run's error is returned; later ones are logged, so a
+// half-saved file does not end the session.
+func watch(ctx context.Context, path string, run func() error, logger *slog.Logger) error {
+	if err := run(); err != nil {
+		return err
+	}
+	for range fileChanges(ctx, path, watchInterval) {
+		if err := run(); err != nil {
+			logger.Warn("re-encode failed", "file", path, "err", err)
+			continue
+		}
+		logger.Info("re-encoded", "file", path)
+	}
+	return nil
+}
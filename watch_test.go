@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// runWriter records each Write as one run's output and signals it on wrote.
+type runWriter struct {
+	mu    sync.Mutex
+	runs  []string
+	wrote chan struct{}
+}
+
+func (w *runWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	w.runs = append(w.runs, string(p))
+	w.mu.Unlock()
+	w.wrote <- struct{}{}
+	return len(p), nil
+}
+
+func TestWatchReencodes(t *testing.T) {
+	t.Setenv(envPrefix+"CONFIG", filepath.Join(t.TempDir(), "none.json"))
+	path := filepath.Join(t.TempDir(), "in.txt")
+	contents := []string{"first", "second, 汪", "third"}
+	if err := os.WriteFile(path, []byte(contents[0]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := make(chan struct{})
+	fileChanges = func(_ context.Context, p string, _ time.Duration) <-chan struct{} {
+		if p != path {
+			t.Errorf("watching %q, want %q", p, path)
+		}
+		return changes
+	}
+	t.Cleanup(func() { fileChanges = pollChanges })
+
+	out := &runWriter{wrote: make(chan struct{}, 1)}
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"encode", "--watch", path})
+	cmd.SetOut(out)
+	cmd.SetErr(new(bytes.Buffer))
+	done := make(chan error, 1)
+	go func() { done <- cmd.Execute() }()
+
+	// Change the file only between runs, once the previous output is out.
+	<-out.wrote
+	for _, c := range contents[1:] {
+		if err := os.WriteFile(path, []byte(c), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		changes <- struct{}{}
+		<-out.wrote
+	}
+	close(changes)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if len(out.runs) != len(contents) {
+		t.Fatalf("%d runs for %d versions of the file: %q", len(out.runs), len(contents), out.runs)
+	}
+	for i, c := range contents {
+		if got, want := strings.TrimSuffix(out.runs[i], "\n"), mustEncode(t, c); got != want {
+			t.Errorf("run %d: got %q, want the encoding of %q", i, got, c)
+		}
+	}
+}